@@ -0,0 +1,67 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// extendableContext is a context whose deadline can be pushed back, which a
+// regular context derived from the caller's cannot. It is detached from the
+// parent's deadline but still follows the parent's cancellation.
+type extendableContext struct {
+	context.Context
+	cancel context.CancelCauseFunc
+
+	mu       sync.Mutex
+	deadline time.Time
+	timer    *time.Timer
+	stop     func() bool
+}
+
+func newExtendableContext(parent context.Context, deadline time.Time) *extendableContext {
+	ctx, cancel := context.WithCancelCause(context.WithoutCancel(parent))
+	c := &extendableContext{Context: ctx, cancel: cancel, deadline: deadline}
+	c.timer = time.AfterFunc(time.Until(deadline), func() {
+		cancel(context.DeadlineExceeded)
+	})
+	c.stop = context.AfterFunc(parent, func() {
+		// the parent's own deadline is what we are allowed to extend
+		if !errors.Is(parent.Err(), context.DeadlineExceeded) {
+			cancel(context.Cause(parent))
+		}
+	})
+	return c
+}
+
+func (c *extendableContext) Deadline() (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.deadline, true
+}
+
+func (c *extendableContext) Err() error {
+	err := c.Context.Err()
+	if err != nil && errors.Is(context.Cause(c.Context), context.DeadlineExceeded) {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+// extend pushes the deadline back by d, unless the context is already done.
+func (c *extendableContext) extend(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Context.Err() != nil {
+		return
+	}
+	c.deadline = c.deadline.Add(d)
+	c.timer.Reset(time.Until(c.deadline))
+}
+
+func (c *extendableContext) release() {
+	c.timer.Stop()
+	c.stop()
+	c.cancel(context.Canceled)
+}
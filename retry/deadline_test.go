@@ -0,0 +1,67 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithDeadlineExtension(t *testing.T) {
+	t.Run("extends deadline on progress", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		initial, _ := ctx.Deadline()
+
+		var counter atomic.Int64
+		var deadlines []time.Time
+		_, err := ExponentialRetryCtx[int](ctx, 3, 30*time.Millisecond, func(ctx context.Context) (int, error) {
+			d, _ := ctx.Deadline()
+			deadlines = append(deadlines, d)
+			counter.Add(10)
+			if len(deadlines) < 4 {
+				return 0, errors.New("still working")
+			}
+			return 1, nil
+		}, WithProgressFunc(counter.Load), WithDeadlineExtension(5, 200*time.Millisecond))
+		if err != nil {
+			t.Fatalf("expected extended deadline to allow all attempts, got %v", err)
+		}
+		if !deadlines[0].Equal(initial) {
+			t.Errorf("expected first attempt to see the caller's deadline")
+		}
+		if !deadlines[3].After(initial) {
+			t.Errorf("expected later attempts to see an extended deadline")
+		}
+	})
+
+	t.Run("no extension without progress", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		_, err := ExponentialRetry[int](ctx, 3, 30*time.Millisecond, func() (int, error) {
+			return 0, errors.New("stuck")
+		}, WithProgressFunc(func() int64 { return 0 }), WithDeadlineExtension(5, 200*time.Millisecond))
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected DeadlineExceeded, got %v", err)
+		}
+	})
+
+	t.Run("parent cancellation still stops the loop", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		var counter atomic.Int64
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			cancel()
+		}()
+
+		_, err := ExponentialRetry[int](ctx, 3, 100*time.Millisecond, func() (int, error) {
+			counter.Add(10)
+			return 0, errors.New("still working")
+		}, WithProgressFunc(counter.Load), WithDeadlineExtension(5, time.Second))
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected Canceled, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrNoDeadline is returned when the caller's context has no deadline, see
+// WithAllowNoDeadline.
+var ErrNoDeadline = errors.New("retry: no deadline set by caller; use context.WithTimeout, context.WithDeadline, or retry.WithAllowNoDeadline() to disable this check")
+
+// ErrCircuitTripped is returned, wrapping the last error, when
+// WithConsecutiveFailureLimit stops the loop.
+var ErrCircuitTripped = errors.New("retry: circuit tripped")
+
+// ErrUnconfirmedSuccess is wrapped in the RetryError returned when the loop
+// ran out of attempts before WithRequireConsecutiveSuccesses was satisfied.
+var ErrUnconfirmedSuccess = errors.New("retry: too few consecutive successes")
+
+// ErrAttemptSkipped is the error of an attempt skipped by WithDecay.
+var ErrAttemptSkipped = errors.New("retry: attempt skipped by decay")
+
+// RetryError is returned when the loop gave up because every retry failed. It
+// wraps the error of the last attempt, or a *MultiError with WithAccumulateErrors.
+// Errors that are not retried, such as those rejected by WithRetryIf or a done
+// context, are returned as is, so errors.Is(err, RetryError{}) tells the
+// retries being exhausted apart from other failures.
+type RetryError struct {
+	LastErr       error
+	Attempts      uint
+	TotalDuration time.Duration
+}
+
+func (e RetryError) Error() string {
+	return fmt.Sprintf("retry: giving up after %d attempts in %s: %v", e.Attempts, e.TotalDuration, e.LastErr)
+}
+
+func (e RetryError) Unwrap() error {
+	return e.LastErr
+}
+
+// Is reports whether target is a RetryError, regardless of its fields.
+func (e RetryError) Is(target error) bool {
+	switch target.(type) {
+	case RetryError, *RetryError:
+		return true
+	}
+	return false
+}
+
+// MultiError holds the errors of all failed attempts, see
+// WithAccumulateErrors. Like the result of errors.Join, it implements
+// Unwrap() []error, so errors.Is and errors.As match any of the attempts.
+type MultiError struct {
+	Errors []error
+}
+
+func (m *MultiError) Error() string {
+	msgs := make([]string, len(m.Errors))
+	for i, err := range m.Errors {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (m *MultiError) Unwrap() []error {
+	return m.Errors
+}
@@ -0,0 +1,289 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithAccumulateErrors(t *testing.T) {
+	errFirst := errors.New("first")
+
+	t.Run("errors.Is reaches every attempt", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		attempts := 0
+		_, err := ExponentialRetry[int](ctx, 4, time.Millisecond, func() (int, error) {
+			attempts++
+			if attempts == 1 {
+				// wrap the sentinel five levels deep
+				err := errFirst
+				for i := 0; i < 5; i++ {
+					err = fmt.Errorf("level %d: %w", i, err)
+				}
+				return 0, err
+			}
+			return 0, fmt.Errorf("attempt %d failed", attempts)
+		}, WithAccumulateErrors())
+
+		var multi *MultiError
+		if !errors.As(err, &multi) {
+			t.Fatalf("expected *MultiError, got %T", err)
+		}
+		if len(multi.Errors) != 5 {
+			t.Fatalf("expected 5 accumulated errors, got %d", len(multi.Errors))
+		}
+		if !errors.Is(err, errFirst) {
+			t.Errorf("expected errors.Is to find the first attempt's error")
+		}
+
+		// the same traversal errors.Join guarantees
+		if !errors.Is(errors.Join(multi.Errors...), errFirst) {
+			t.Errorf("expected errors.Join of the same errors to match as well")
+		}
+	})
+
+	t.Run("context error is appended", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		_, err := ExponentialRetry[int](ctx, 4, time.Hour, func() (int, error) {
+			return 0, errFirst
+		}, WithAccumulateErrors())
+		if !errors.Is(err, errFirst) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected both the attempt and the context error, got %v", err)
+		}
+	})
+
+	t.Run("success is not wrapped", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		attempts := 0
+		_, err := ExponentialRetry[int](ctx, 4, time.Millisecond, func() (int, error) {
+			attempts++
+			if attempts < 2 {
+				return 0, errFirst
+			}
+			return 1, nil
+		}, WithAccumulateErrors())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestMultiError_Error(t *testing.T) {
+	err := &MultiError{Errors: []error{errors.New("a"), errors.New("b")}}
+	if err.Error() != "a\nb" {
+		t.Errorf("expected messages joined by newlines like errors.Join, got %q", err.Error())
+	}
+}
+
+func TestRetryError(t *testing.T) {
+	errLast := errors.New("last")
+	var err error = &RetryError{LastErr: errLast, Attempts: 3, TotalDuration: time.Second}
+
+	if !errors.Is(err, RetryError{}) || !errors.Is(err, &RetryError{}) {
+		t.Errorf("expected errors.Is to match any RetryError")
+	}
+	if !errors.Is(err, errLast) {
+		t.Errorf("expected RetryError to unwrap to the last error")
+	}
+	if want := "retry: giving up after 3 attempts in 1s: last"; err.Error() != want {
+		t.Errorf("expected %q, got %q", want, err.Error())
+	}
+}
+
+func TestRetryError_AccumulateErrors(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err := ExponentialRetry[int](ctx, 1, time.Millisecond, func() (int, error) {
+		return 0, errors.New("fail")
+	}, WithAccumulateErrors())
+
+	var exhausted *RetryError
+	var multi *MultiError
+	if !errors.As(err, &exhausted) || !errors.As(exhausted.LastErr, &multi) || len(multi.Errors) != 2 {
+		t.Fatalf("expected RetryError wrapping both attempts, got %v", err)
+	}
+}
+
+func TestWithWrapErrors(t *testing.T) {
+	errFail := errors.New("fail")
+	fail := func() (int, error) { return 0, errFail }
+
+	t.Run("exhausted", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		_, err := ExponentialRetry[int](ctx, 2, time.Millisecond, fail, WithWrapErrors())
+		var exhausted *RetryError
+		if !errors.As(err, &exhausted) {
+			t.Fatalf("expected RetryError, got %v", err)
+		}
+		wrapped := exhausted.LastErr
+		if errors.Unwrap(wrapped) != errFail {
+			t.Fatalf("expected wrapped error to unwrap to the original, got %v", errors.Unwrap(wrapped))
+		}
+		want := fmt.Sprintf("attempt 3/3 after %s: fail", exhausted.TotalDuration)
+		if wrapped.Error() != want {
+			t.Errorf("expected %q, got %q", want, wrapped.Error())
+		}
+	})
+
+	t.Run("not retried", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		_, err := ExponentialRetry[int](ctx, 2, time.Millisecond, fail,
+			WithWrapErrors(), WithRetryIf(func(error) bool { return false }))
+		if errors.Unwrap(err) != errFail || !strings.HasPrefix(err.Error(), "attempt 1/3 after ") {
+			t.Fatalf("expected wrapped first attempt, got %v", err)
+		}
+	})
+
+	t.Run("with accumulated errors", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		_, err := ExponentialRetry[int](ctx, 1, time.Millisecond, fail, WithWrapErrors(), WithAccumulateErrors())
+		var exhausted *RetryError
+		var multi *MultiError
+		if !errors.As(err, &exhausted) || !errors.As(exhausted.LastErr, &multi) || len(multi.Errors) != 2 {
+			t.Fatalf("expected RetryError wrapping both attempts, got %v", err)
+		}
+		want := fmt.Sprintf("fail\nattempt 2/2 after %s: fail", exhausted.TotalDuration)
+		if got := multi.Error(); got != want {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+		if errors.Unwrap(multi.Errors[1]) != errFail {
+			t.Errorf("expected the last error to unwrap to the original, got %v", multi.Errors[1])
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		_, err := ExponentialRetry[int](ctx, 2, time.Millisecond, fail, WithRetryIf(func(error) bool { return false }))
+		if err != errFail {
+			t.Fatalf("expected the raw error, got %v", err)
+		}
+	})
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string { return "timeout" }
+
+func TestWithConsecutiveFailureLimit(t *testing.T) {
+	t.Run("trips on the same error type", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		attempts := 0
+		_, err := ExponentialRetry[int](ctx, 10, time.Millisecond, func() (int, error) {
+			attempts++
+			if attempts == 1 {
+				return 0, fmt.Errorf("request: %w", errors.New("other"))
+			}
+			return 0, fmt.Errorf("request %d: %w", attempts, timeoutError{})
+		}, WithConsecutiveFailureLimit(3))
+
+		if !errors.Is(err, ErrCircuitTripped) || !errors.Is(err, timeoutError{}) {
+			t.Fatalf("expected tripped circuit wrapping the timeout, got %v", err)
+		}
+		if errors.Is(err, RetryError{}) {
+			t.Errorf("expected a trip not to be reported as exhaustion")
+		}
+		// the first error differs, so the streak starts with attempt 2
+		if attempts != 4 {
+			t.Errorf("expected 4 attempts, got %d", attempts)
+		}
+	})
+
+	t.Run("changing errors do not trip", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		attempts := 0
+		_, err := ExponentialRetry[int](ctx, 3, time.Millisecond, func() (int, error) {
+			attempts++
+			if attempts%2 == 0 {
+				return 0, timeoutError{}
+			}
+			return 0, errors.New("other")
+		}, WithConsecutiveFailureLimit(2))
+		if errors.Is(err, ErrCircuitTripped) || !errors.Is(err, RetryError{}) {
+			t.Fatalf("expected exhausted retries, got %v", err)
+		}
+	})
+
+	t.Run("distinct sentinels do not trip", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		attempts := 0
+		_, err := ExponentialRetry[int](ctx, 3, time.Millisecond, func() (int, error) {
+			attempts++
+			if attempts%2 == 0 {
+				return 0, io.EOF
+			}
+			return 0, fmt.Errorf("read: %w", io.ErrUnexpectedEOF)
+		}, WithConsecutiveFailureLimit(2))
+		if errors.Is(err, ErrCircuitTripped) || !errors.Is(err, RetryError{}) {
+			t.Fatalf("expected exhausted retries, got %v", err)
+		}
+	})
+
+	t.Run("trips on the same sentinel", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		attempts := 0
+		_, err := ExponentialRetry[int](ctx, 10, time.Millisecond, func() (int, error) {
+			attempts++
+			return 0, fmt.Errorf("read %d: %w", attempts, io.EOF)
+		}, WithConsecutiveFailureLimit(2))
+		if !errors.Is(err, ErrCircuitTripped) || attempts != 2 {
+			t.Fatalf("expected a trip after 2 attempts, got %v after %d", err, attempts)
+		}
+	})
+
+	t.Run("trips on freshly built errors", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		attempts := 0
+		_, err := ExponentialRetry[int](ctx, 10, time.Millisecond, func() (int, error) {
+			attempts++
+			return 0, fmt.Errorf("status %d", 503)
+		}, WithConsecutiveFailureLimit(2))
+		if !errors.Is(err, ErrCircuitTripped) || attempts != 2 {
+			t.Fatalf("expected a trip after 2 attempts, got %v after %d", err, attempts)
+		}
+	})
+
+	t.Run("trip is kept with accumulated errors", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		_, err := ExponentialRetry[int](ctx, 10, time.Millisecond, func() (int, error) {
+			return 0, io.EOF
+		}, WithConsecutiveFailureLimit(2), WithAccumulateErrors())
+		var multi *MultiError
+		if !errors.As(err, &multi) || len(multi.Errors) != 2 {
+			t.Fatalf("expected the errors of 2 attempts, got %v", err)
+		}
+		if !errors.Is(err, ErrCircuitTripped) || !errors.Is(err, io.EOF) {
+			t.Errorf("expected tripped circuit wrapping io.EOF, got %v", err)
+		}
+	})
+}
@@ -1,3 +1,8 @@
 module github.com/peeperklip/stuff/retry
 
 go 1.24
+
+require (
+	go.opentelemetry.io/otel v1.38.0
+	go.opentelemetry.io/otel/trace v1.38.0
+)
@@ -0,0 +1,68 @@
+package retry
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Stats describes the last run of an InstrumentedRetry.
+type Stats struct {
+	TotalAttempts uint
+	// SuccessAttempt is the attempt that succeeded, or -1 if none did.
+	SuccessAttempt   int
+	TotalDuration    time.Duration
+	BackoffDurations []time.Duration
+	Errors           []error
+}
+
+// InstrumentedRetry runs ExponentialRetry and records Stats about each run,
+// without the caller having to register hooks.
+type InstrumentedRetry[T any] struct {
+	mu    sync.Mutex
+	stats Stats
+}
+
+// NewInstrumentedRetry returns an InstrumentedRetry whose Stats report no run
+// yet.
+func NewInstrumentedRetry[T any]() *InstrumentedRetry[T] {
+	return &InstrumentedRetry[T]{stats: Stats{SuccessAttempt: -1}}
+}
+
+// ExponentialRetry behaves like the package level ExponentialRetry and
+// replaces the recorded Stats once it returns.
+func (r *InstrumentedRetry[T]) ExponentialRetry(ctx context.Context, maxRetries uint, baseBackoff time.Duration, fn func() (T, error), opts ...RetryOption) (T, error) {
+	stats := Stats{SuccessAttempt: -1}
+	cfg := newConfig(append([]RetryOption{WithMaxRetries(maxRetries), WithBaseBackoff(baseBackoff)}, opts...))
+	cfg.onRetry = append(cfg.onRetry, func(_ uint, _ error, backoff time.Duration) {
+		stats.BackoffDurations = append(stats.BackoffDurations, backoff)
+	})
+
+	start := time.Now()
+	result, err := run(ctx, cfg, func(ctx context.Context) (T, error) {
+		stats.TotalAttempts++
+		v, err := fn()
+		if err != nil {
+			stats.Errors = append(stats.Errors, err)
+		} else {
+			stats.SuccessAttempt = int(CurrentAttempt(ctx))
+		}
+		return v, err
+	})
+	stats.TotalDuration = time.Since(start)
+
+	r.mu.Lock()
+	r.stats = stats
+	r.mu.Unlock()
+	return result, err
+}
+
+// Stats returns a snapshot of the statistics of the last run.
+func (r *InstrumentedRetry[T]) Stats() Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.stats
+	s.BackoffDurations = append([]time.Duration(nil), s.BackoffDurations...)
+	s.Errors = append([]error(nil), s.Errors...)
+	return s
+}
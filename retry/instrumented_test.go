@@ -0,0 +1,63 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestInstrumentedRetry(t *testing.T) {
+	t.Run("records a successful run", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		r := NewInstrumentedRetry[int]()
+		attempts := 0
+		_, err := r.ExponentialRetry(ctx, 5, 2*time.Millisecond, func() (int, error) {
+			attempts++
+			if attempts < 3 {
+				return 0, errors.New("fail")
+			}
+			return 1, nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		s := r.Stats()
+		if s.TotalAttempts != 3 || s.SuccessAttempt != 2 {
+			t.Errorf("expected 3 attempts with success at 2, got %+v", s)
+		}
+		if len(s.Errors) != 2 {
+			t.Errorf("expected 2 errors, got %v", s.Errors)
+		}
+		if len(s.BackoffDurations) != 2 || s.BackoffDurations[0] != 2*time.Millisecond || s.BackoffDurations[1] != 4*time.Millisecond {
+			t.Errorf("expected backoffs [2ms 4ms], got %v", s.BackoffDurations)
+		}
+		if s.TotalDuration < 6*time.Millisecond {
+			t.Errorf("expected total duration of at least 6ms, got %v", s.TotalDuration)
+		}
+	})
+
+	t.Run("records a failed run", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		r := NewInstrumentedRetry[int]()
+		_, _ = r.ExponentialRetry(ctx, 1, time.Millisecond, func() (int, error) {
+			return 0, errors.New("fail")
+		})
+
+		s := r.Stats()
+		if s.TotalAttempts != 2 || s.SuccessAttempt != -1 || len(s.Errors) != 2 {
+			t.Errorf("expected 2 failed attempts, got %+v", s)
+		}
+	})
+
+	t.Run("stats before the first run", func(t *testing.T) {
+		if s := NewInstrumentedRetry[int]().Stats(); s.SuccessAttempt != -1 || s.TotalAttempts != 0 {
+			t.Errorf("expected empty stats, got %+v", s)
+		}
+	})
+}
@@ -0,0 +1,85 @@
+package retry
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// WithLogger sends log entries of the retry loop to logger instead of the
+// default slog logger. It replaces any writer set with WithWriter.
+func WithLogger(logger *slog.Logger) RetryOption {
+	return func(c *config) {
+		c.logger = logger
+		c.writer = nil
+	}
+}
+
+// WithWriter writes human readable log lines to w instead of using slog, for
+// example:
+//
+//	[retry] attempt=1 err="connection refused" delay=200ms
+//
+// Besides the entries slog would receive, a line is written for every retry.
+// It replaces any logger set with WithLogger.
+func WithWriter(w io.Writer) RetryOption {
+	return func(c *config) {
+		c.writer = w
+		c.logger = nil
+	}
+}
+
+func (c *config) logInfo(msg string, attrs ...slog.Attr) {
+	if c.operation != "" {
+		attrs = append([]slog.Attr{slog.String("operation", c.operation)}, attrs...)
+	}
+	if c.writer != nil {
+		c.writeLine(msg, attrs)
+		return
+	}
+	logger := c.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	args := make([]any, len(attrs))
+	for i, a := range attrs {
+		args[i] = a
+	}
+	logger.Info(msg, args...)
+}
+
+// logRetry records a failed attempt that is about to be retried. Only
+// WithWriter reports these.
+func (c *config) logRetry(attempt uint, err error, delay time.Duration) {
+	if c.writer == nil {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.Uint64("attempt", uint64(attempt)),
+		slog.String("err", err.Error()),
+		slog.Duration("delay", delay),
+	}
+	if c.operation != "" {
+		attrs = append([]slog.Attr{slog.String("operation", c.operation)}, attrs...)
+	}
+	c.writeLine("", attrs)
+}
+
+func (c *config) writeLine(msg string, attrs []slog.Attr) {
+	var b strings.Builder
+	b.WriteString("[retry]")
+	if msg != "" {
+		b.WriteString(" " + msg)
+	}
+	for _, a := range attrs {
+		if a.Value.Kind() == slog.KindString {
+			fmt.Fprintf(&b, " %s=%q", a.Key, a.Value.String())
+		} else {
+			fmt.Fprintf(&b, " %s=%s", a.Key, a.Value.String())
+		}
+	}
+	b.WriteString("\n")
+	_, _ = io.WriteString(c.writer, b.String())
+}
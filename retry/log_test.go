@@ -0,0 +1,66 @@
+package retry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithWriter(t *testing.T) {
+	slogged := captureLogs(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var buf bytes.Buffer
+	_, _ = ExponentialRetry[int](ctx, 2, 2*time.Millisecond, func() (int, error) {
+		return 0, errors.New("connection refused")
+	}, WithWriter(&buf))
+
+	want := "[retry] attempt=0 err=\"connection refused\" delay=2ms\n" +
+		"[retry] attempt=1 err=\"connection refused\" delay=4ms\n"
+	if buf.String() != want {
+		t.Errorf("unexpected writer output:\n%s\nexpected:\n%s", buf.String(), want)
+	}
+	if slogged.Len() != 0 {
+		t.Errorf("expected nothing to be logged through slog, got %q", slogged.String())
+	}
+}
+
+func TestWithWriter_ContextDone(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	var buf bytes.Buffer
+	_, _ = ExponentialRetry[int](ctx, 2, time.Hour, func() (int, error) {
+		return 0, errors.New("connection refused")
+	}, WithWriter(&buf), WithOperationName("fetch"))
+
+	if !strings.Contains(buf.String(), `[retry] deadline exceeded operation="fetch" attempt=0`) {
+		t.Errorf("expected deadline line in writer output, got %q", buf.String())
+	}
+}
+
+func TestWithLogger(t *testing.T) {
+	slogged := captureLogs(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	var buf, written bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	_, _ = ExponentialRetry[int](ctx, 2, time.Hour, func() (int, error) {
+		return 0, errors.New("connection refused")
+	}, WithWriter(&written), WithLogger(logger))
+
+	if !strings.Contains(buf.String(), "deadline exceeded") {
+		t.Errorf("expected custom logger to receive the entry, got %q", buf.String())
+	}
+	if written.Len() != 0 || slogged.Len() != 0 {
+		t.Errorf("expected only the last of WithWriter and WithLogger to be active")
+	}
+}
@@ -0,0 +1,828 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"math/bits"
+	"math/rand"
+	"reflect"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RetryOption configures optional behaviour of the retry loop.
+type RetryOption func(*config)
+
+// Defaults used by the entry points that do not take maxRetries and
+// baseBackoff as arguments, such as ExponentialRetryStream.
+const (
+	DefaultMaxRetries  uint          = 3
+	DefaultBaseBackoff time.Duration = 100 * time.Millisecond
+)
+
+type config struct {
+	maxRetries    uint
+	minMaxRetries uint
+	// requiredSuccesses is at least 1
+	requiredSuccesses uint
+	baseBackoff       time.Duration
+	// growth factor num/denom per attempt, doubling when denom is 0
+	num, denom     uint64
+	operation      string
+	logger         *slog.Logger
+	writer         io.Writer
+	initialAttempt uint
+	attemptBase    uint
+	backoffCap     time.Duration
+	timeBudget     time.Duration
+	jitter         float64
+	seed           *int64
+	rand           *rand.Rand
+	// wait computes the backoff, see WithWaitBetweenAttempts
+	wait func(attempt uint, err error) time.Duration
+
+	backoffChan <-chan struct{}
+	timerPool   *sync.Pool
+	semaphore   chan struct{}
+	limiter     interface{ Wait(context.Context) error }
+
+	recoverPanics   bool
+	accumulate      bool
+	wrapErrors      bool
+	onCancel        func() error
+	dryRun          func(attempt uint, err error)
+	warmup          bool
+	allowNoDeadline bool
+	minRetries      uint
+
+	tracer      trace.Tracer
+	sleepTracer trace.Tracer
+
+	retryIf      func(error) bool
+	breaker      func() error
+	decay        float64
+	errorBudget  map[error]uint
+	errorRetries map[error]uint
+
+	failureLimit uint
+	failureErr   error
+	failures     uint
+
+	attemptContexts     []func(ctx context.Context, i uint) (context.Context, context.CancelFunc)
+	firstAttemptTimeout time.Duration
+	propagate           bool
+
+	progress          func() int64
+	progressThreshold int64
+	extension         time.Duration
+
+	// onRetry is called for every failed attempt that will be retried,
+	// before waiting for backoff.
+	onRetry []func(attempt uint, err error, backoff time.Duration)
+	after   []func(totalAttempts uint, finalErr error)
+}
+
+func newConfig(opts []RetryOption) *config {
+	cfg := &config{
+		maxRetries:        DefaultMaxRetries,
+		baseBackoff:       DefaultBaseBackoff,
+		requiredSuccesses: 1,
+	}
+	for _, o := range opts {
+		o(cfg)
+	}
+	if cfg.maxRetries < cfg.minMaxRetries {
+		panic(fmt.Sprintf("retry: maxRetries is %d, expected at least %d", cfg.maxRetries, cfg.minMaxRetries))
+	}
+	if cfg.backoffCap > 0 && cfg.backoffCap < cfg.baseBackoff {
+		panic(fmt.Sprintf("retry: backoff cap %s is below the base backoff %s", cfg.backoffCap, cfg.baseBackoff))
+	}
+	if cfg.timeBudget > 0 {
+		cfg.maxRetries = cfg.retriesWithin(cfg.timeBudget)
+	}
+	if cfg.wait == nil {
+		cfg.wait = func(attempt uint, _ error) time.Duration {
+			return cfg.backoff(attempt)
+		}
+	}
+	return cfg
+}
+
+// WithMaxRetries sets the number of retries after the initial attempt, so 0
+// means exactly one attempt.
+func WithMaxRetries(n uint) RetryOption {
+	return func(c *config) {
+		c.maxRetries = n
+	}
+}
+
+// WithMultiplierRational grows the backoff by num/denom per attempt instead
+// of doubling it, e.g. 3/2 for 1.5x. The backoff is computed exactly, as
+// baseBackoff * num^attempt / denom^attempt rounded down, as long as the
+// intermediate values fit into 64 bits, and in floating point beyond that.
+// num must be at least denom, and denom must not be 0.
+func WithMultiplierRational(num, denom uint) RetryOption {
+	if denom == 0 || num < denom {
+		panic("retry: multiplier must be a fraction of at least 1")
+	}
+	return func(c *config) {
+		c.num, c.denom = uint64(num), uint64(denom)
+	}
+}
+
+// grow returns baseBackoff grown for the given attempt, and whether it
+// exceeds MaxInt64.
+func (c *config) grow(attempt uint) (time.Duration, bool) {
+	if c.denom == 0 {
+		if attempt >= 63 || c.baseBackoff > math.MaxInt64>>attempt {
+			return 0, true
+		}
+		return c.baseBackoff * time.Duration(1<<attempt), false
+	}
+
+	n, d := uint64(c.baseBackoff), uint64(1)
+	for k := uint(0); k < attempt; k++ {
+		nHi, nLo := bits.Mul64(n, c.num)
+		dHi, dLo := bits.Mul64(d, c.denom)
+		if nHi != 0 || dHi != 0 {
+			f := float64(c.baseBackoff) * math.Pow(float64(c.num)/float64(c.denom), float64(attempt))
+			if f >= math.MaxInt64 {
+				return 0, true
+			}
+			return time.Duration(f), false
+		}
+		n, d = nLo, dLo
+	}
+	if v := n / d; v <= math.MaxInt64 {
+		return time.Duration(v), false
+	}
+	return 0, true
+}
+
+// WithMinMaxRetries asserts that the loop is configured with at least min
+// retries, and panics when it starts otherwise. Use it to catch a maxRetries
+// of 0 passed by mistake, which is valid and means a single attempt.
+func WithMinMaxRetries(min uint) RetryOption {
+	return func(c *config) {
+		c.minMaxRetries = min
+	}
+}
+
+// WithRequireConsecutiveSuccesses makes the loop return only once fn
+// succeeded n times in a row, for checks where a single success may be a
+// fluke, such as a flapping health check. It returns the value of the last
+// success. Successes count as attempts, each is confirmed after the base
+// backoff, and a failure resets the count. If the attempts run out first, the
+// loop returns a RetryError wrapping ErrUnconfirmedSuccess.
+func WithRequireConsecutiveSuccesses(n uint) RetryOption {
+	return func(c *config) {
+		c.requiredSuccesses = max(n, 1)
+	}
+}
+
+// WithBaseBackoff sets the delay after the first failed attempt; it doubles
+// with every following attempt, see WithMultiplierRational for other rates.
+func WithBaseBackoff(d time.Duration) RetryOption {
+	return func(c *config) {
+		c.baseBackoff = d
+	}
+}
+
+// WithOperationName tags every log entry of the retry loop with
+// slog.String("operation", name), so concurrent loops can be told apart.
+func WithOperationName(name string) RetryOption {
+	return func(c *config) {
+		c.operation = name
+	}
+}
+
+// WithInitialAttempt sets the number of the first attempt as reported by
+// CurrentAttempt, hooks and logs. Nested retry loops can use it to continue
+// numbering where the outer loop left off; it does not change the number of
+// retries or the backoff.
+func WithInitialAttempt(n uint) RetryOption {
+	return func(c *config) {
+		c.initialAttempt = n
+	}
+}
+
+// WithAttemptBase shifts the attempt numbers passed to hooks, log entries and
+// trace spans, so base 1 reports "attempt 1 of 3" where base 0 reports
+// attempt 0. CurrentAttempt stays zero-based. base must be 0 or 1.
+func WithAttemptBase(base uint) RetryOption {
+	if base > 1 {
+		panic("retry: attempt base must be 0 or 1")
+	}
+	return func(c *config) {
+		c.attemptBase = base
+	}
+}
+
+// label returns the attempt number as reported to hooks and logs.
+func (c *config) label(attempt uint) uint {
+	return attempt + c.attemptBase
+}
+
+// WithOnRetry registers fn to be called for every failed attempt that will be
+// retried, with the error and the backoff the loop is about to wait.
+func WithOnRetry(fn func(attempt uint, err error, backoff time.Duration)) RetryOption {
+	return func(c *config) {
+		c.onRetry = append(c.onRetry, fn)
+	}
+}
+
+// WithBackoffNotify sends every backoff to ch before the loop waits for it.
+// The send does not block: values are dropped while ch is full.
+func WithBackoffNotify(ch chan<- time.Duration) RetryOption {
+	return WithOnRetry(func(_ uint, _ error, backoff time.Duration) {
+		select {
+		case ch <- backoff:
+		default:
+		}
+	})
+}
+
+// WithWaitBetweenAttempts replaces the built-in exponential backoff with
+// wait, which gets the zero-based number of the failed attempt, unaffected by
+// WithInitialAttempt, and its error, and returns how long to wait before the
+// next one, e.g. for step functions or delays looked up elsewhere. The growth,
+// cap and jitter options only shape the built-in backoff and have no effect.
+// WithTimeBudget calls wait with a nil error to add up the delays.
+func WithWaitBetweenAttempts(wait func(attempt uint, err error) time.Duration) RetryOption {
+	return func(c *config) {
+		c.wait = wait
+	}
+}
+
+// WithBackoffCap sets a deterministic ceiling on the exponential backoff.
+// The cap is applied to the computed backoff before any jitter is added, so
+// the total delay may slightly exceed the cap while the base backoff does not.
+// A cap below the base backoff would turn the backoff constant and panics
+// when the loop starts.
+func WithBackoffCap(cap time.Duration) RetryOption {
+	return func(c *config) {
+		c.backoffCap = cap
+	}
+}
+
+// WithJitter adds a random delay of up to factor times the backoff to every
+// wait, so clients failing at the same time do not retry in lockstep. factor
+// must not be negative.
+func WithJitter(factor float64) RetryOption {
+	if factor < 0 {
+		panic("retry: negative jitter factor")
+	}
+	return func(c *config) {
+		c.jitter = factor
+	}
+}
+
+// WithSeed seeds the random source used by WithJitter and WithDecay, making
+// the sequence of delays and skipped attempts reproducible. Without it the
+// source is seeded from the clock.
+func WithSeed(seed int64) RetryOption {
+	return func(c *config) {
+		c.seed = &seed
+	}
+}
+
+// WithTimeBudget caps the number of retries to as many as fit into budget:
+// the backoffs waited before them, at their maximum including jitter or as
+// returned by WithWaitBetweenAttempts, add up to at most budget. The time spent in fn is not accounted for; use a context
+// deadline to bound the total time. A larger WithMaxRetries is lowered, a
+// smaller one kept.
+func WithTimeBudget(budget time.Duration) RetryOption {
+	return func(c *config) {
+		c.timeBudget = budget
+	}
+}
+
+// retriesWithin returns the number of retries, up to maxRetries, whose
+// backoffs add up to at most budget. It runs before the built-in backoff is
+// set as the default wait, so c.wait is only set by WithWaitBetweenAttempts.
+func (c *config) retriesWithin(budget time.Duration) uint {
+	var total time.Duration
+	for i := uint(0); i < c.maxRetries; i++ {
+		d, jitter := c.delay(i), c.jitter
+		if c.wait != nil {
+			d, jitter = c.wait(i, nil), 0
+		}
+		if d <= 0 {
+			// zero backoffs never exhaust the budget
+			return c.maxRetries
+		}
+		// compare as float, the jitter may push d beyond MaxInt64
+		worst := float64(d) * (1 + jitter)
+		if worst > float64(budget-total) {
+			return i
+		}
+		total += time.Duration(worst)
+	}
+	return c.maxRetries
+}
+
+// WithRecoverPanics converts a panic in fn into an error, which is then
+// retried like any other error. Without it panics propagate to the caller.
+func WithRecoverPanics() RetryOption {
+	return func(c *config) {
+		c.recoverPanics = true
+	}
+}
+
+// WithAfterFunc registers fn to be called exactly once when the retry loop
+// returns, whether it succeeded or not. fn receives the number of times the
+// retried function was called and the error returned to the caller, nil on
+// success.
+func WithAfterFunc(fn func(totalAttempts uint, finalErr error)) RetryOption {
+	return func(c *config) {
+		c.after = append(c.after, fn)
+	}
+}
+
+// WithProgressFunc sets a function reporting how much work fn has done so far,
+// e.g. a byte counter. It is used by WithDeadlineExtension.
+func WithProgressFunc(progress func() int64) RetryOption {
+	return func(c *config) {
+		c.progress = progress
+	}
+}
+
+// WithDeadlineExtension extends the context deadline by extra whenever the
+// progress reported by WithProgressFunc grew by more than threshold during the
+// last attempt. The extended deadline may exceed the caller's deadline, but
+// cancelling the caller's context still stops the loop.
+func WithDeadlineExtension(threshold int64, extra time.Duration) RetryOption {
+	return func(c *config) {
+		c.progressThreshold = threshold
+		c.extension = extra
+	}
+}
+
+// WithWarmupAttempt makes the first attempt before the context deadline is
+// checked, so calls that usually succeed right away skip that overhead. Only
+// when the first attempt fails is the deadline required, after which the
+// regular backoff sequence continues.
+func WithWarmupAttempt() RetryOption {
+	return func(c *config) {
+		c.warmup = true
+	}
+}
+
+// WithAllowNoDeadline lets the loop run on a context without a deadline,
+// which is rejected by default so a failing dependency cannot keep the caller
+// retrying forever. The retry limit still bounds the loop, and cancelling the
+// context still stops it.
+func WithAllowNoDeadline() RetryOption {
+	return func(c *config) {
+		c.allowNoDeadline = true
+	}
+}
+
+// WithHandleCancellation replaces context.Canceled by the error returned from
+// fn when the caller cancels the context while the loop is waiting. An
+// exceeded deadline is still reported as context.DeadlineExceeded, and a
+// cancellation with a cause as that cause.
+func WithHandleCancellation(fn func() error) RetryOption {
+	return func(c *config) {
+		c.onCancel = fn
+	}
+}
+
+// WithCanceledError is WithHandleCancellation under the name used by callers
+// that only want to tell a user-initiated stop apart from a timeout.
+func WithCanceledError(fn func() error) RetryOption {
+	return WithHandleCancellation(fn)
+}
+
+// contextErr returns the error to report once ctx is done: the cause given
+// to the context's CancelCauseFunc, if any, and ctx.Err() otherwise.
+func (c *config) contextErr(ctx context.Context) error {
+	err := context.Cause(ctx)
+	if c.onCancel != nil && err == context.Canceled {
+		return c.onCancel()
+	}
+	return err
+}
+
+// WithDryRun reports the outcome of every attempt to report. The retries and
+// the waits between them still run, but success is only returned if the first
+// attempt succeeded: once an attempt failed, the loop stops at the next
+// success and returns the error of the attempt before it. Use it to measure
+// how often retries would be needed before relying on their results.
+func WithDryRun(report func(attempt uint, err error)) RetryOption {
+	return func(c *config) {
+		c.dryRun = report
+	}
+}
+
+// WithMinRetries guarantees n retries after the initial attempt, even if the
+// caller's context is cancelled or its deadline passes in the meantime. The
+// guaranteed attempts and the waits before them run on a context detached
+// from the caller's cancellation and deadline; later attempts do not. The
+// guarantee never exceeds the configured number of retries.
+func WithMinRetries(n uint) RetryOption {
+	return func(c *config) {
+		c.minRetries = n
+	}
+}
+
+// guaranteed returns the context to use for the i-th attempt: detached from
+// ctx's cancellation when the attempt is covered by WithMinRetries.
+func (c *config) guaranteed(ctx context.Context, i uint) context.Context {
+	if c.minRetries > 0 && i <= c.minRetries {
+		return context.WithoutCancel(ctx)
+	}
+	return ctx
+}
+
+// WithAccumulateErrors returns a *MultiError holding the error of every
+// attempt, in order, instead of only the last one when the loop fails.
+func WithAccumulateErrors() RetryOption {
+	return func(c *config) {
+		c.accumulate = true
+	}
+}
+
+// WithWrapErrors annotates the error of the attempt the loop gives up on with
+// the attempt number, the retry limit and the time spent, as in
+// "attempt 3/3 after 7ms: <err>". errors.Is and errors.As still reach the
+// original error. With WithAccumulateErrors the last of the errors is
+// annotated.
+func WithWrapErrors() RetryOption {
+	return func(c *config) {
+		c.wrapErrors = true
+	}
+}
+
+func (c *config) wrapErr(err error, i uint, elapsed time.Duration) error {
+	if !c.wrapErrors {
+		return err
+	}
+	return fmt.Errorf("attempt %d/%d after %s: %w", i+1, c.maxRetries+1, elapsed, err)
+}
+
+// WithAttemptContextFunc derives the context of every attempt by calling fn
+// with the context the attempt would otherwise get and the attempt number as
+// reported by CurrentAttempt, e.g. to add values or spans. Multiple functions
+// are applied in order. The parent is canceled once the attempt is over, which
+// releases the deadlines and timers fn derived from it.
+func WithAttemptContextFunc(fn func(parent context.Context, attempt uint) context.Context) RetryOption {
+	return func(c *config) {
+		c.attemptContexts = append(c.attemptContexts, func(ctx context.Context, i uint) (context.Context, context.CancelFunc) {
+			parent, cancel := context.WithCancel(ctx)
+			return fn(parent, c.initialAttempt+i), cancel
+		})
+	}
+}
+
+// WithAttemptTimeout bounds every attempt by d, through a deadline on the
+// context passed to fn by ExponentialRetryCtx.
+func WithAttemptTimeout(d time.Duration) RetryOption {
+	return func(c *config) {
+		WithAttemptContextFunc(func(ctx context.Context, attempt uint) context.Context {
+			if d <= 0 || attempt == c.initialAttempt && c.firstAttemptTimeout > 0 {
+				return ctx
+			}
+			ctx, cancel := context.WithTimeout(ctx, d)
+			// canceling the parent after the attempt stops the timer
+			_ = cancel
+			return ctx
+		})(c)
+	}
+}
+
+// WithFirstAttemptTimeout bounds only the first attempt by d, overriding
+// WithAttemptTimeout for it. Use it to give a cold start more time than the
+// retries that follow.
+func WithFirstAttemptTimeout(d time.Duration) RetryOption {
+	return func(c *config) {
+		c.firstAttemptTimeout = d
+	}
+}
+
+// WithAttemptSemaphore limits how many attempts run at the same time across
+// all retry loops sharing sem: every call of fn first sends to sem and
+// receives from it once fn returns, so the capacity of sem is the limit. An
+// attempt whose context is done while waiting for sem fails with the context's
+// cause without calling fn.
+func WithAttemptSemaphore(sem chan struct{}) RetryOption {
+	return func(c *config) {
+		c.semaphore = sem
+	}
+}
+
+func (c *config) acquire(ctx context.Context) bool {
+	if c.semaphore == nil {
+		return true
+	}
+	select {
+	case c.semaphore <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (c *config) release() {
+	if c.semaphore != nil {
+		<-c.semaphore
+	}
+}
+
+// WithRateLimit calls limiter.Wait before every attempt, so a function that
+// fails fast cannot burn through the retry budget before the backoff grows.
+// golang.org/x/time/rate.Limiter satisfies the interface. An error from Wait
+// is returned right away, replaced by the context's cause if the context is
+// done.
+func WithRateLimit(limiter interface{ Wait(context.Context) error }) RetryOption {
+	return func(c *config) {
+		c.limiter = limiter
+	}
+}
+
+// WithRetryIf only retries errors for which retryIf returns true; any other
+// error is returned to the caller right away.
+func WithRetryIf(retryIf func(error) bool) RetryOption {
+	return func(c *config) {
+		c.retryIf = retryIf
+	}
+}
+
+// WithCircuitBreakerCheck calls check before every attempt, including the
+// first. If check returns an error, fn is not called and the error takes the
+// place of the attempt's error: it is retried after the usual backoff unless
+// WithRetryIf rejects it. Skipped attempts count towards the retry limit.
+func WithCircuitBreakerCheck(check func() error) RetryOption {
+	return func(c *config) {
+		c.breaker = check
+	}
+}
+
+func (c *config) checkBreaker() error {
+	if c.breaker == nil {
+		return nil
+	}
+	return c.breaker()
+}
+
+// WithDecay skips the n-th attempt, counting from 1, with probability
+// rate^n, modelling an operation that becomes more likely to succeed over
+// time, such as waiting for a resource being provisioned. A skipped attempt
+// does not call fn and fails with ErrAttemptSkipped, like an attempt rejected
+// by WithCircuitBreakerCheck. The trials use the source set by WithSeed. rate
+// must be in [0, 1).
+func WithDecay(rate float64) RetryOption {
+	if rate < 0 || rate >= 1 {
+		panic("retry: decay rate must be in [0, 1)")
+	}
+	return func(c *config) {
+		c.decay = rate
+	}
+}
+
+// checkDecay runs the trial of WithDecay for the i-th attempt.
+func (c *config) checkDecay(i uint) error {
+	if c.decay == 0 {
+		return nil
+	}
+	if c.random().Float64() < math.Pow(c.decay, float64(i+1)) {
+		return ErrAttemptSkipped
+	}
+	return nil
+}
+
+// WithErrorBudget gives errors matching a key of budget (via errors.Is) their
+// own retry limit. Once an error has been retried budget[key] times, its next
+// occurrence is returned as is, without waiting for the overall retry limit.
+func WithErrorBudget(budget map[error]uint) RetryOption {
+	return func(c *config) {
+		c.errorBudget = budget
+		c.errorRetries = make(map[error]uint, len(budget))
+	}
+}
+
+// WithConsecutiveFailureLimit stops the loop once n attempts in a row failed
+// with an error of the same type, e.g. n consecutive syscall.Errno, and returns
+// ErrCircuitTripped wrapping the last error. This is a lightweight circuit
+// breaker without state shared between loops. The innermost errors of the
+// Unwrap chains are compared: by message for errors made with errors.New or
+// fmt.Errorf, such as io.EOF, which have no type of their own, and by type
+// otherwise. errors.Is(err, ErrCircuitTripped) tells a trip apart from
+// exhausted retries.
+func WithConsecutiveFailureLimit(n uint) RetryOption {
+	return func(c *config) {
+		c.failureLimit = n
+	}
+}
+
+// tripped records the failed attempt and reports whether the consecutive
+// failure limit has been reached.
+func (c *config) tripped(err error) bool {
+	if c.failureLimit == 0 {
+		return false
+	}
+	// compare the innermost error, so wrapping with fmt.Errorf does not
+	// make every error look the same
+	for inner := errors.Unwrap(err); inner != nil; inner = errors.Unwrap(inner) {
+		err = inner
+	}
+	if sameFailure(err, c.failureErr) {
+		c.failures++
+	} else {
+		c.failureErr, c.failures = err, 1
+	}
+	return c.failures >= c.failureLimit
+}
+
+// opaqueError is the type of errors.New and fmt.Errorf without %w, shared by
+// unrelated errors.
+var opaqueError = reflect.TypeOf(errors.New(""))
+
+// sameFailure reports whether err and prev count as the same failure, see
+// WithConsecutiveFailureLimit.
+func sameFailure(err, prev error) bool {
+	if prev == nil {
+		return false
+	}
+	typ := reflect.TypeOf(err)
+	if typ != reflect.TypeOf(prev) {
+		return false
+	}
+	if typ == opaqueError {
+		// a fresh error is usually built for every attempt
+		return err.Error() == prev.Error()
+	}
+	return true
+}
+
+// permanent reports whether err must not be retried, and accounts for the
+// retry otherwise.
+func (c *config) permanent(err error) bool {
+	if c.retryIf != nil && !c.retryIf(err) {
+		return true
+	}
+	for target, limit := range c.errorBudget {
+		if !errors.Is(err, target) {
+			continue
+		}
+		if c.errorRetries[target] >= limit {
+			return true
+		}
+		c.errorRetries[target]++
+	}
+	return false
+}
+
+// attemptContext derives the context for the i-th attempt.
+func (c *config) attemptContext(ctx context.Context, i uint) (context.Context, context.CancelFunc) {
+	var cancels []context.CancelFunc
+	for _, derive := range c.attemptContexts {
+		var cancel context.CancelFunc
+		ctx, cancel = derive(ctx, i)
+		cancels = append(cancels, cancel)
+	}
+	if i == 0 && c.firstAttemptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.firstAttemptTimeout)
+		cancels = append(cancels, cancel)
+	}
+	return ctx, func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}
+}
+
+// WithContextPropagation guarantees that every value of the caller's context
+// can be looked up from the context passed to fn, however the attempt context
+// is derived, e.g. by WithAttemptTimeout or WithMinRetries. Attempt contexts
+// already inherit the caller's values; this option makes that contract
+// explicit for code relying on it, by falling back to the caller's context
+// for values the attempt context does not carry.
+func WithContextPropagation() RetryOption {
+	return func(c *config) {
+		c.propagate = true
+	}
+}
+
+// valuesFrom is a context that looks up values missing from Context in caller.
+type valuesFrom struct {
+	context.Context
+	caller context.Context
+}
+
+func (v valuesFrom) Value(key any) any {
+	if val := v.Context.Value(key); val != nil {
+		return val
+	}
+	return v.caller.Value(key)
+}
+
+// WithBackoffChan makes the loop wait for a value on ch instead of sleeping
+// for the computed backoff, handing control over retry timing to an external
+// coordinator. Cancelling the context still ends the wait.
+func WithBackoffChan(ch <-chan struct{}) RetryOption {
+	return func(c *config) {
+		c.backoffChan = ch
+	}
+}
+
+// WithTimerPool makes the loop wait for the backoff on a *time.Timer taken from
+// pool instead of allocating a new timer per wait, and return it to pool
+// afterwards. Share one pool between loops to reduce GC pressure in
+// high-throughput callers. The pool may be empty or have a New function; any
+// value that is not a *time.Timer is ignored.
+func WithTimerPool(pool *sync.Pool) RetryOption {
+	return func(c *config) {
+		c.timerPool = pool
+	}
+}
+
+// timer returns a timer firing after d, from the timer pool if one is set.
+func (c *config) timer(d time.Duration) *time.Timer {
+	if c.timerPool != nil {
+		if t, ok := c.timerPool.Get().(*time.Timer); ok {
+			t.Reset(d)
+			return t
+		}
+	}
+	return time.NewTimer(d)
+}
+
+// sleep waits for the backoff d and reports false if ctx was done first.
+func (c *config) sleep(ctx context.Context, d time.Duration) bool {
+	if c.backoffChan != nil {
+		select {
+		case <-c.backoffChan:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	if c.timerPool == nil {
+		select {
+		case <-time.After(d):
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	t := c.timer(d)
+	defer func() {
+		// since Go 1.23 Stop discards a pending value, so the timer can be
+		// reset by the next user without draining its channel
+		t.Stop()
+		c.timerPool.Put(t)
+	}()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// delay returns the capped exponential backoff after the given failed attempt,
+// without jitter.
+func (c *config) delay(attempt uint) time.Duration {
+	backoff, overflow := c.grow(attempt)
+	if overflow && c.backoffCap <= 0 {
+		return math.MaxInt64
+	}
+	if c.backoffCap > 0 && (overflow || backoff > c.backoffCap) {
+		backoff = c.backoffCap
+	}
+	return backoff
+}
+
+// random returns the source for jitter and decay, seeded by WithSeed or the
+// clock on first use.
+func (c *config) random() *rand.Rand {
+	if c.rand == nil {
+		seed := time.Now().UnixNano()
+		if c.seed != nil {
+			seed = *c.seed
+		}
+		c.rand = rand.New(rand.NewSource(seed))
+	}
+	return c.rand
+}
+
+// backoff returns the delay to wait after the given failed attempt.
+func (c *config) backoff(attempt uint) time.Duration {
+	backoff := c.delay(attempt)
+	if c.jitter > 0 {
+		extra := time.Duration(c.random().Float64() * c.jitter * float64(backoff))
+		if extra > math.MaxInt64-backoff {
+			return math.MaxInt64
+		}
+		backoff += extra
+	}
+	return backoff
+}
@@ -0,0 +1,1236 @@
+package retry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/big"
+	"math/rand"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// captureLogs redirects the default slog logger into a buffer for the
+// duration of the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return &buf
+}
+
+func TestWithOperationName(t *testing.T) {
+	buf := captureLogs(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := ExponentialRetry[int](ctx, 5, 100*time.Millisecond, func() (int, error) {
+		return 0, errors.New("transient")
+	}, WithOperationName("fetchUserProfile"))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+	if !strings.Contains(buf.String(), "operation=fetchUserProfile") {
+		t.Fatalf("expected operation attribute in log output, got %q", buf.String())
+	}
+}
+
+func TestWithoutOperationName(t *testing.T) {
+	buf := captureLogs(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, _ = ExponentialRetry[int](ctx, 5, 100*time.Millisecond, func() (int, error) {
+		return 0, errors.New("transient")
+	})
+	if strings.Contains(buf.String(), "operation=") {
+		t.Fatalf("expected no operation attribute in log output, got %q", buf.String())
+	}
+}
+
+func TestWithBackoffCap(t *testing.T) {
+	cfg := newConfig([]RetryOption{WithBaseBackoff(10 * time.Millisecond), WithBackoffCap(35 * time.Millisecond)})
+
+	want := []time.Duration{
+		10 * time.Millisecond,
+		20 * time.Millisecond,
+		35 * time.Millisecond, // 40ms capped
+		35 * time.Millisecond, // 80ms capped
+	}
+	for attempt, w := range want {
+		if got := cfg.backoff(uint(attempt)); got != w {
+			t.Errorf("attempt %d: expected backoff %v, got %v", attempt, w, got)
+		}
+	}
+
+	// the cap also protects against the shift overflowing for large attempts
+	if got := cfg.backoff(62); got != 35*time.Millisecond {
+		t.Errorf("expected overflowed backoff to be capped, got %v", got)
+	}
+}
+
+func TestWithBackoffCap_WithJitter(t *testing.T) {
+	const limit = 35 * time.Millisecond
+	cfg := newConfig([]RetryOption{WithBaseBackoff(10 * time.Millisecond), WithBackoffCap(limit), WithJitter(1), WithSeed(1)})
+
+	// the cap bounds the base backoff, the jitter is added on top of it
+	exceeded := false
+	for attempt := uint(2); attempt < 20; attempt++ {
+		if base := cfg.delay(attempt); base != limit {
+			t.Errorf("attempt %d: expected base backoff capped at %v, got %v", attempt, limit, base)
+		}
+		got := cfg.backoff(attempt)
+		if got < limit || got > 2*limit {
+			t.Errorf("attempt %d: expected backoff in [%v, %v], got %v", attempt, limit, 2*limit, got)
+		}
+		exceeded = exceeded || got > limit
+	}
+	if !exceeded {
+		t.Errorf("expected the jitter to push some backoff beyond the cap")
+	}
+}
+
+func TestWithBackoffCap_BelowBase(t *testing.T) {
+	tests := []struct {
+		name      string
+		cap       time.Duration
+		wantPanic bool
+	}{
+		{"equal to base", 10 * time.Millisecond, false},
+		{"below base", 5 * time.Millisecond, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if panicked := recover() != nil; panicked != tt.wantPanic {
+					t.Errorf("expected panic %v, got %v", tt.wantPanic, panicked)
+				}
+			}()
+			cfg := newConfig([]RetryOption{WithBaseBackoff(10 * time.Millisecond), WithBackoffCap(tt.cap)})
+			if got := cfg.backoff(3); got != tt.cap {
+				t.Errorf("expected constant backoff %v, got %v", tt.cap, got)
+			}
+		})
+	}
+}
+
+func TestWithoutBackoffCap(t *testing.T) {
+	cfg := newConfig([]RetryOption{WithBaseBackoff(10 * time.Millisecond)})
+	if got := cfg.backoff(3); got != 80*time.Millisecond {
+		t.Errorf("expected uncapped backoff 80ms, got %v", got)
+	}
+}
+
+func TestWithWaitBetweenAttempts(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	slow := errors.New("slow")
+	type call struct {
+		attempt uint
+		err     error
+	}
+	var calls []call
+	var backoffs []time.Duration
+	_, err := ExponentialRetry[int](ctx, 3, time.Hour, func() (int, error) {
+		return 0, slow
+	}, WithWaitBetweenAttempts(func(attempt uint, err error) time.Duration {
+		calls = append(calls, call{attempt, err})
+		// a step function: the same short delay, then a longer one
+		if attempt < 2 {
+			return time.Millisecond
+		}
+		return 2 * time.Millisecond
+	}), WithOnRetry(func(_ uint, _ error, backoff time.Duration) {
+		backoffs = append(backoffs, backoff)
+	}))
+	if !errors.Is(err, slow) {
+		t.Fatalf("expected %v, got %v", slow, err)
+	}
+	want := []time.Duration{time.Millisecond, time.Millisecond, 2 * time.Millisecond}
+	if !slices.Equal(backoffs, want) {
+		t.Errorf("expected backoffs %v, got %v", want, backoffs)
+	}
+	for i, c := range calls {
+		if c.attempt != uint(i) || c.err != slow {
+			t.Errorf("call %d: unexpected %+v", i, c)
+		}
+	}
+}
+
+func TestWithJitter(t *testing.T) {
+	cfg := newConfig([]RetryOption{WithBaseBackoff(10 * time.Millisecond), WithJitter(0.5)})
+	for attempt := uint(0); attempt < 4; attempt++ {
+		base := 10 * time.Millisecond << attempt
+		if got := cfg.backoff(attempt); got < base || got > base+base/2 {
+			t.Errorf("attempt %d: expected backoff in [%v, %v], got %v", attempt, base, base+base/2, got)
+		}
+	}
+}
+
+func TestWithSeed(t *testing.T) {
+	delays := func() []time.Duration {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		var got []time.Duration
+		_, _ = ExponentialRetry[int](ctx, 3, time.Millisecond, func() (int, error) {
+			return 0, errors.New("fail")
+		}, WithJitter(1), WithSeed(42), WithOnRetry(func(_ uint, _ error, backoff time.Duration) {
+			got = append(got, backoff)
+		}))
+		return got
+	}
+
+	first, second := delays(), delays()
+	if len(first) != 3 || fmt.Sprint(first) != fmt.Sprint(second) {
+		t.Fatalf("expected the same 3 delays for the same seed, got %v and %v", first, second)
+	}
+	for i, d := range first {
+		if base := time.Millisecond << i; d < base || d > 2*base {
+			t.Errorf("retry %d: expected delay in [%v, %v], got %v", i, base, 2*base, d)
+		}
+	}
+}
+
+func TestWithRecoverPanics(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	attempts := 0
+	val, err := ExponentialRetry[int](ctx, 3, time.Millisecond, func() (int, error) {
+		attempts++
+		if attempts == 1 {
+			panic("boom")
+		}
+		return 5, nil
+	}, WithRecoverPanics())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if val != 5 || attempts != 2 {
+		t.Fatalf("expected 5 after 2 attempts, got %v after %d", val, attempts)
+	}
+}
+
+func TestWithRecoverPanics_Exhausted(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err := ExponentialRetry[int](ctx, 1, time.Millisecond, func() (int, error) {
+		panic("boom")
+	}, WithRecoverPanics())
+	if err == nil || !strings.HasPrefix(errors.Unwrap(err).Error(), "panic: boom\n") {
+		t.Fatalf("expected panic error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "goroutine") {
+		t.Errorf("expected stack trace in error, got %q", err.Error())
+	}
+}
+
+func TestWithoutRecoverPanics(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Fatalf("expected panic 'boom' to propagate, got %v", r)
+		}
+	}()
+	_, _ = ExponentialRetry[int](ctx, 1, time.Millisecond, func() (int, error) {
+		panic("boom")
+	})
+}
+
+func TestWithInitialAttempt(t *testing.T) {
+	buf := captureLogs(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	var seen []uint
+	_, err := ExponentialRetryCtx[int](ctx, 5, time.Millisecond, func(ctx context.Context) (int, error) {
+		seen = append(seen, CurrentAttempt(ctx))
+		if len(seen) < 3 {
+			return 0, errors.New("fail")
+		}
+		return 1, nil
+	}, WithInitialAttempt(2))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(seen) != 3 || seen[0] != 2 || seen[1] != 3 || seen[2] != 4 {
+		t.Fatalf("expected attempts [2 3 4], got %v", seen)
+	}
+
+	// the offset does not extend the retry budget, and is used in logs
+	attempts := 0
+	_, _ = ExponentialRetry[int](ctx, 5, time.Hour, func() (int, error) {
+		attempts++
+		return 0, errors.New("fail")
+	}, WithInitialAttempt(2))
+	if attempts != 1 {
+		t.Fatalf("expected a single attempt before the deadline, got %d", attempts)
+	}
+	if !strings.Contains(buf.String(), "attempt=2") {
+		t.Errorf("expected offset attempt in log output, got %q", buf.String())
+	}
+}
+
+func TestWithAfterFunc(t *testing.T) {
+	type call struct {
+		attempts uint
+		err      error
+	}
+
+	t.Run("success", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		var calls []call
+		n := 0
+		_, err := ExponentialRetry[int](ctx, 5, time.Millisecond, func() (int, error) {
+			n++
+			if n < 3 {
+				return 0, errors.New("fail")
+			}
+			return 1, nil
+		}, WithAfterFunc(func(attempts uint, err error) {
+			calls = append(calls, call{attempts, err})
+		}))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(calls) != 1 || calls[0].attempts != 3 || calls[0].err != nil {
+			t.Fatalf("expected a single call with 3 attempts and nil error, got %+v", calls)
+		}
+	})
+
+	t.Run("exhausted", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		var calls []call
+		permanent := errors.New("permanent failure")
+		_, _ = ExponentialRetry[int](ctx, 2, time.Millisecond, func() (int, error) {
+			return 0, permanent
+		}, WithAfterFunc(func(attempts uint, err error) {
+			calls = append(calls, call{attempts, err})
+		}))
+		if len(calls) != 1 || calls[0].attempts != 3 || !errors.Is(calls[0].err, permanent) {
+			t.Fatalf("expected a single call with 3 attempts and the last error, got %+v", calls)
+		}
+	})
+
+	t.Run("no deadline", func(t *testing.T) {
+		var calls []call
+		_, _ = ExponentialRetry[int](context.Background(), 2, time.Millisecond, func() (int, error) {
+			return 0, nil
+		}, WithAfterFunc(func(attempts uint, err error) {
+			calls = append(calls, call{attempts, err})
+		}))
+		if len(calls) != 1 || calls[0].attempts != 0 || calls[0].err == nil {
+			t.Fatalf("expected a single call with 0 attempts and an error, got %+v", calls)
+		}
+	})
+}
+
+func TestWithFirstAttemptTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var budgets []time.Duration
+	_, _ = ExponentialRetryCtx[int](ctx, 2, time.Millisecond, func(ctx context.Context) (int, error) {
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			t.Fatalf("expected attempt context to have a deadline")
+		}
+		budgets = append(budgets, time.Until(deadline))
+		return 0, errors.New("fail")
+	}, WithFirstAttemptTimeout(500*time.Millisecond), WithAttemptTimeout(50*time.Millisecond))
+
+	if len(budgets) != 3 {
+		t.Fatalf("expected 3 attempts, got %d", len(budgets))
+	}
+	if budgets[0] <= 400*time.Millisecond || budgets[0] > 500*time.Millisecond {
+		t.Errorf("expected first attempt to get the 500ms timeout, got %v", budgets[0])
+	}
+	for i, b := range budgets[1:] {
+		if b > 50*time.Millisecond {
+			t.Errorf("attempt %d: expected the 50ms attempt timeout, got %v", i+1, b)
+		}
+	}
+}
+
+func TestWithAttemptTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	attempts := 0
+	_, err := ExponentialRetryCtx[int](ctx, 1, time.Millisecond, func(ctx context.Context) (int, error) {
+		attempts++
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}, WithAttemptTimeout(5*time.Millisecond))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected attempt timeout, got %v", err)
+	}
+	if attempts != 2 {
+		t.Fatalf("expected both attempts to time out individually, got %d attempts", attempts)
+	}
+}
+
+func TestWithDecay(t *testing.T) {
+	t.Run("skips attempts with decaying probability", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		// replay the trials with the same seed
+		src := rand.New(rand.NewSource(7))
+		var want []bool
+		for n := 1; len(want) == 0 || !want[len(want)-1]; n++ {
+			want = append(want, src.Float64() >= math.Pow(0.8, float64(n)))
+		}
+		var got []bool
+		val, err := ExponentialRetry[int](ctx, uint(len(want)), time.Microsecond, func() (int, error) {
+			got = append(got, true)
+			return 4, nil
+		}, WithDecay(0.8), WithSeed(7), WithOnRetry(func(_ uint, err error, _ time.Duration) {
+			if !errors.Is(err, ErrAttemptSkipped) {
+				t.Errorf("expected ErrAttemptSkipped, got %v", err)
+			}
+			got = append(got, false)
+		}))
+		if err != nil || val != 4 {
+			t.Fatalf("expected 4, got %v, %v", val, err)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("expected attempts %v, got %v", want, got)
+		}
+	})
+
+	t.Run("skips count towards the retry limit", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		// with rate 0.999 and a seed of 1 all three attempts are skipped
+		calls := 0
+		_, err := ExponentialRetry[int](ctx, 2, time.Microsecond, func() (int, error) {
+			calls++
+			return 0, nil
+		}, WithDecay(0.999), WithSeed(1))
+		var retryErr *RetryError
+		if !errors.As(err, &retryErr) || !errors.Is(err, ErrAttemptSkipped) || calls != 0 {
+			t.Fatalf("expected a RetryError for skipped attempts, got %v after %d calls", err, calls)
+		}
+	})
+
+	t.Run("rejects rates outside [0, 1)", func(t *testing.T) {
+		for _, rate := range []float64{-0.1, 1} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Errorf("expected panic for rate %v", rate)
+					}
+				}()
+				WithDecay(rate)
+			}()
+		}
+	})
+}
+
+func TestWithErrorBudget(t *testing.T) {
+	errFlaky := errors.New("flaky")
+	errOverloaded := errors.New("overloaded")
+
+	t.Run("stops once a budget is spent", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		attempts := 0
+		_, err := ExponentialRetry[int](ctx, 10, time.Millisecond, func() (int, error) {
+			attempts++
+			return 0, fmt.Errorf("call failed: %w", errOverloaded)
+		}, WithErrorBudget(map[error]uint{errOverloaded: 2}))
+		if !errors.Is(err, errOverloaded) {
+			t.Fatalf("expected overloaded error, got %v", err)
+		}
+		if attempts != 3 {
+			t.Fatalf("expected initial attempt plus 2 retries, got %d attempts", attempts)
+		}
+	})
+
+	t.Run("budgets are tracked per error", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		sequence := []error{errFlaky, errOverloaded, errFlaky, errFlaky, nil}
+		attempts := 0
+		_, err := ExponentialRetry[int](ctx, 10, time.Millisecond, func() (int, error) {
+			err := sequence[attempts]
+			attempts++
+			return 0, err
+		}, WithErrorBudget(map[error]uint{errFlaky: 3, errOverloaded: 1}))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if attempts != 5 {
+			t.Fatalf("expected 5 attempts, got %d", attempts)
+		}
+	})
+
+	t.Run("other errors use the overall limit", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		attempts := 0
+		_, _ = ExponentialRetry[int](ctx, 4, time.Millisecond, func() (int, error) {
+			attempts++
+			return 0, errFlaky
+		}, WithErrorBudget(map[error]uint{errOverloaded: 0}))
+		if attempts != 5 {
+			t.Fatalf("expected 5 attempts, got %d", attempts)
+		}
+	})
+}
+
+func TestWithWarmupAttempt(t *testing.T) {
+	t.Run("succeeds without a deadline", func(t *testing.T) {
+		val, err := ExponentialRetry[int](context.Background(), 3, time.Millisecond, func() (int, error) {
+			return 9, nil
+		}, WithWarmupAttempt())
+		if err != nil || val != 9 {
+			t.Fatalf("expected 9, got %v, %v", val, err)
+		}
+	})
+
+	t.Run("requires a deadline once warm-up failed", func(t *testing.T) {
+		attempts := 0
+		_, err := ExponentialRetry[int](context.Background(), 3, time.Millisecond, func() (int, error) {
+			attempts++
+			return 0, errors.New("fail")
+		}, WithWarmupAttempt())
+		if !errors.Is(err, ErrNoDeadline) {
+			t.Fatalf("expected no deadline error, got %v", err)
+		}
+		if attempts != 1 {
+			t.Fatalf("expected only the warm-up attempt, got %d", attempts)
+		}
+	})
+
+	t.Run("continues with backoff after warm-up", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		var seen []uint
+		_, err := ExponentialRetryCtx[int](ctx, 3, time.Millisecond, func(ctx context.Context) (int, error) {
+			seen = append(seen, CurrentAttempt(ctx))
+			if len(seen) < 3 {
+				return 0, errors.New("fail")
+			}
+			return 1, nil
+		}, WithWarmupAttempt())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(seen) != 3 || seen[0] != 0 || seen[2] != 2 {
+			t.Fatalf("expected attempts [0 1 2], got %v", seen)
+		}
+	})
+}
+
+func TestWithHandleCancellation(t *testing.T) {
+	errStopped := errors.New("stopped by user")
+	fail := func() (int, error) { return 0, errors.New("transient") }
+
+	for name, option := range map[string]func(func() error) RetryOption{
+		"WithHandleCancellation": WithHandleCancellation,
+		"WithCanceledError":      WithCanceledError,
+	} {
+		handle := option(func() error { return errStopped })
+
+		t.Run(name+"/cancellation is converted", func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			time.AfterFunc(10*time.Millisecond, cancel)
+
+			_, err := ExponentialRetry[int](ctx, 5, 100*time.Millisecond, fail, handle)
+			if !errors.Is(err, errStopped) {
+				t.Fatalf("expected converted cancellation error, got %v", err)
+			}
+		})
+
+		t.Run(name+"/deadline is unchanged", func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+
+			_, err := ExponentialRetry[int](ctx, 5, 100*time.Millisecond, fail, handle)
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("expected DeadlineExceeded, got %v", err)
+			}
+		})
+	}
+}
+
+func TestWithDryRun(t *testing.T) {
+	type report struct {
+		attempt uint
+		err     error
+	}
+
+	t.Run("returns last error even after success", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		var reports []report
+		errTransient := errors.New("transient")
+		attempts := 0
+		_, err := ExponentialRetry[int](ctx, 5, time.Millisecond, func() (int, error) {
+			attempts++
+			if attempts < 3 {
+				return 0, errTransient
+			}
+			return 1, nil
+		}, WithDryRun(func(attempt uint, err error) {
+			reports = append(reports, report{attempt, err})
+		}))
+		if !errors.Is(err, errTransient) {
+			t.Fatalf("expected last error in dry run, got %v", err)
+		}
+		if len(reports) != 3 || reports[2].err != nil || reports[1].err == nil {
+			t.Fatalf("expected 3 reports ending in success, got %+v", reports)
+		}
+	})
+
+	t.Run("first attempt success is returned", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		reports := 0
+		val, err := ExponentialRetry[int](ctx, 5, time.Millisecond, func() (int, error) {
+			return 4, nil
+		}, WithDryRun(func(uint, error) { reports++ }))
+		if err != nil || val != 4 || reports != 1 {
+			t.Fatalf("expected 4 with a single report, got %v, %v, %d reports", val, err, reports)
+		}
+	})
+}
+
+func TestWithBackoffChan(t *testing.T) {
+	t.Run("advances on the channel instead of the clock", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		ch := make(chan struct{}, 3)
+		for i := 0; i < 3; i++ {
+			ch <- struct{}{}
+		}
+
+		attempts := 0
+		start := time.Now()
+		_, err := ExponentialRetry[int](ctx, 3, time.Hour, func() (int, error) {
+			attempts++
+			return 0, errors.New("fail")
+		}, WithBackoffChan(ch))
+		if err == nil || attempts != 4 {
+			t.Fatalf("expected 4 failed attempts, got %d, %v", attempts, err)
+		}
+		if time.Since(start) > 500*time.Millisecond {
+			t.Errorf("expected the hour long backoff to be skipped")
+		}
+	})
+
+	t.Run("context ends the wait", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		_, err := ExponentialRetry[int](ctx, 3, time.Millisecond, func() (int, error) {
+			return 0, errors.New("fail")
+		}, WithBackoffChan(make(chan struct{})))
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected DeadlineExceeded, got %v", err)
+		}
+	})
+}
+
+func TestWithMinRetries(t *testing.T) {
+	t.Run("guaranteed attempts survive the deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+		defer cancel()
+
+		attempts := 0
+		_, err := ExponentialRetry[int](ctx, 5, 10*time.Millisecond, func() (int, error) {
+			attempts++
+			return 0, errors.New("fail")
+		}, WithMinRetries(2))
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected DeadlineExceeded after the guaranteed attempts, got %v", err)
+		}
+		if attempts != 3 {
+			t.Fatalf("expected exactly 3 attempts, got %d", attempts)
+		}
+	})
+
+	t.Run("guaranteed attempts survive cancellation", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		cancel()
+
+		attempts := 0
+		val, err := ExponentialRetryCtx[int](ctx, 5, time.Millisecond, func(ctx context.Context) (int, error) {
+			attempts++
+			if ctx.Err() != nil {
+				t.Errorf("attempt %d: expected a live context, got %v", attempts, ctx.Err())
+			}
+			if attempts < 2 {
+				return 0, errors.New("fail")
+			}
+			return 8, nil
+		}, WithMinRetries(1))
+		if err != nil || val != 8 {
+			t.Fatalf("expected 8, got %v, %v", val, err)
+		}
+	})
+
+	t.Run("bounded by max retries", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		attempts := 0
+		_, _ = ExponentialRetry[int](ctx, 1, time.Millisecond, func() (int, error) {
+			attempts++
+			return 0, errors.New("fail")
+		}, WithMinRetries(5))
+		if attempts != 2 {
+			t.Fatalf("expected 2 attempts, got %d", attempts)
+		}
+	})
+}
+
+func TestWithRetryIf(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	errFatal := errors.New("fatal")
+	attempts := 0
+	_, err := ExponentialRetry[int](ctx, 5, time.Millisecond, func() (int, error) {
+		attempts++
+		if attempts == 2 {
+			return 0, errFatal
+		}
+		return 0, errors.New("transient")
+	}, WithRetryIf(func(err error) bool { return !errors.Is(err, errFatal) }))
+	if !errors.Is(err, errFatal) {
+		t.Fatalf("expected fatal error, got %v", err)
+	}
+	if attempts != 2 {
+		t.Fatalf("expected to stop after 2 attempts, got %d", attempts)
+	}
+}
+
+func TestWithCircuitBreakerCheck(t *testing.T) {
+	errOpen := errors.New("circuit open")
+
+	t.Run("skips attempts while open", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		checks, calls := 0, 0
+		val, err := ExponentialRetry[int](ctx, 5, time.Millisecond, func() (int, error) {
+			calls++
+			return 6, nil
+		}, WithCircuitBreakerCheck(func() error {
+			checks++
+			if checks < 3 {
+				return errOpen
+			}
+			return nil
+		}))
+		if err != nil || val != 6 {
+			t.Fatalf("expected 6, got %v, %v", val, err)
+		}
+		if checks != 3 || calls != 1 {
+			t.Fatalf("expected 3 checks and 1 call, got %d checks and %d calls", checks, calls)
+		}
+	})
+
+	t.Run("returns breaker error when not retriable", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		calls := 0
+		_, err := ExponentialRetry[int](ctx, 5, time.Millisecond, func() (int, error) {
+			calls++
+			return 0, nil
+		}, WithCircuitBreakerCheck(func() error { return errOpen }),
+			WithRetryIf(func(err error) bool { return !errors.Is(err, errOpen) }))
+		if !errors.Is(err, errOpen) || calls != 0 {
+			t.Fatalf("expected circuit open error without calls, got %v after %d calls", err, calls)
+		}
+	})
+
+	t.Run("skipped attempts count towards the limit", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		checks := 0
+		_, err := ExponentialRetry[int](ctx, 2, time.Millisecond, func() (int, error) {
+			return 0, nil
+		}, WithCircuitBreakerCheck(func() error {
+			checks++
+			return errOpen
+		}))
+		if !errors.Is(err, errOpen) || checks != 3 {
+			t.Fatalf("expected circuit open error after 3 checks, got %v after %d", err, checks)
+		}
+	})
+}
+
+func TestWithOnRetry(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var attempts []uint
+	var backoffs []time.Duration
+	_, _ = ExponentialRetry[int](ctx, 2, time.Millisecond, func() (int, error) {
+		return 0, errors.New("fail")
+	}, WithOnRetry(func(attempt uint, _ error, backoff time.Duration) {
+		attempts = append(attempts, attempt)
+		backoffs = append(backoffs, backoff)
+	}))
+
+	// the final attempt is not retried, so it does not trigger the hook
+	if len(attempts) != 2 || attempts[0] != 0 || attempts[1] != 1 {
+		t.Fatalf("expected hook for attempts [0 1], got %v", attempts)
+	}
+	if backoffs[0] != time.Millisecond || backoffs[1] != 2*time.Millisecond {
+		t.Fatalf("expected backoffs [1ms 2ms], got %v", backoffs)
+	}
+}
+
+func TestWithBackoffNotify(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	fail := func() (int, error) { return 0, errors.New("fail") }
+
+	ch := make(chan time.Duration, 3)
+	_, _ = ExponentialRetry[int](ctx, 3, time.Millisecond, fail, WithBackoffNotify(ch))
+	close(ch)
+	var got []time.Duration
+	for d := range ch {
+		got = append(got, d)
+	}
+	if fmt.Sprint(got) != fmt.Sprint([]time.Duration{time.Millisecond, 2 * time.Millisecond, 4 * time.Millisecond}) {
+		t.Fatalf("expected backoffs [1ms 2ms 4ms], got %v", got)
+	}
+
+	// a full channel drops values instead of blocking the loop
+	full := make(chan time.Duration)
+	_, err := ExponentialRetry[int](ctx, 3, time.Millisecond, fail, WithBackoffNotify(full))
+	if !errors.Is(err, RetryError{}) {
+		t.Fatalf("expected retries to be exhausted, got %v", err)
+	}
+}
+
+func TestWithAttemptBase(t *testing.T) {
+	buf := captureLogs(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+
+	var hooked, reported, current []uint
+	_, _ = ExponentialRetryCtx[int](ctx, 5, 10*time.Millisecond, func(ctx context.Context) (int, error) {
+		current = append(current, CurrentAttempt(ctx))
+		return 0, errors.New("fail")
+	}, WithAttemptBase(1),
+		WithOnRetry(func(attempt uint, _ error, _ time.Duration) { hooked = append(hooked, attempt) }),
+		WithDryRun(func(attempt uint, _ error) { reported = append(reported, attempt) }))
+
+	if len(hooked) < 2 || hooked[0] != 1 || hooked[1] != 2 {
+		t.Errorf("expected one-based hook attempts, got %v", hooked)
+	}
+	if len(reported) < 2 || reported[0] != 1 || reported[1] != 2 {
+		t.Errorf("expected one-based dry run attempts, got %v", reported)
+	}
+	if current[0] != 0 {
+		t.Errorf("expected CurrentAttempt to stay zero-based, got %v", current)
+	}
+	want := fmt.Sprintf("attempt=%d", len(current))
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("expected %q in log output, got %q", want, buf.String())
+	}
+}
+
+func TestWithAttemptBase_Invalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic for attempt base 2")
+		}
+	}()
+	WithAttemptBase(2)
+}
+
+func TestWithTimerPool(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var created int
+	pool := &sync.Pool{New: func() any {
+		created++
+		return time.NewTimer(time.Hour)
+	}}
+
+	calls := 0
+	v, err := ExponentialRetry[int](ctx, 3, time.Millisecond, func() (int, error) {
+		calls++
+		if calls < 4 {
+			return 0, errors.New("fail")
+		}
+		return 42, nil
+	}, WithTimerPool(pool))
+	if err != nil || v != 42 {
+		t.Fatalf("expected 42, got %d, %v", v, err)
+	}
+	// the pool may drop values at any time, but a timer that was put back
+	// must be stopped and reusable
+	if created > 3 {
+		t.Errorf("expected at most one timer per wait, got %d", created)
+	}
+	// without New, Get only returns a timer the loop put back, not a fresh
+	// running one, which -race makes likely by dropping pooled values
+	pool.New = nil
+	if tm, ok := pool.Get().(*time.Timer); ok && tm.Stop() {
+		t.Errorf("expected pooled timer to be stopped")
+	}
+}
+
+func TestWithAttemptSemaphore(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sem := make(chan struct{}, 2)
+	var mu sync.Mutex
+	var active, peak int
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			calls := 0
+			_, _ = ExponentialRetry[int](ctx, 2, time.Millisecond, func() (int, error) {
+				mu.Lock()
+				active++
+				peak = max(peak, active)
+				mu.Unlock()
+				time.Sleep(5 * time.Millisecond)
+				mu.Lock()
+				active--
+				mu.Unlock()
+				if calls++; calls < 2 {
+					return 0, errors.New("fail")
+				}
+				return 1, nil
+			}, WithAttemptSemaphore(sem))
+		}()
+	}
+	wg.Wait()
+
+	if peak > 2 {
+		t.Fatalf("expected at most 2 concurrent attempts, got %d", peak)
+	}
+	if len(sem) != 0 {
+		t.Fatalf("expected semaphore to be released, %d slots held", len(sem))
+	}
+}
+
+func TestWithAttemptSemaphore_ContextDone(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	sem := make(chan struct{}, 1)
+	sem <- struct{}{} // held by someone else
+	called := false
+	_, err := ExponentialRetry[int](ctx, 1, time.Millisecond, func() (int, error) {
+		called = true
+		return 1, nil
+	}, WithAttemptSemaphore(sem))
+	if called || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded without calling fn, got %v (called %v)", err, called)
+	}
+}
+
+type requestIDKey struct{}
+
+func TestAttemptContext_InheritsValues(t *testing.T) {
+	options := map[string][]RetryOption{
+		"default":             nil,
+		"attempt timeout":     {WithAttemptTimeout(time.Second)},
+		"first timeout":       {WithFirstAttemptTimeout(time.Second)},
+		"min retries":         {WithMinRetries(1)},
+		"context propagation": {WithContextPropagation(), WithAttemptTimeout(time.Second)},
+	}
+	for name, opts := range options {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), requestIDKey{}, "req-42"), time.Second)
+			defer cancel()
+
+			var seen []any
+			_, _ = ExponentialRetryCtx[int](ctx, 1, time.Millisecond, func(ctx context.Context) (int, error) {
+				seen = append(seen, ctx.Value(requestIDKey{}))
+				return 0, errors.New("fail")
+			}, opts...)
+			if len(seen) != 2 || seen[0] != "req-42" || seen[1] != "req-42" {
+				t.Fatalf("expected request id in every attempt, got %v", seen)
+			}
+		})
+	}
+}
+
+func TestWithContextPropagation(t *testing.T) {
+	caller := context.WithValue(context.Background(), requestIDKey{}, "req-42")
+	// an attempt context that lost the caller's values, e.g. by being
+	// derived from context.Background
+	ctx := valuesFrom{Context: context.WithValue(context.Background(), attemptKey{}, uint(1)), caller: caller}
+	if ctx.Value(requestIDKey{}) != "req-42" {
+		t.Errorf("expected caller value to be found, got %v", ctx.Value(requestIDKey{}))
+	}
+	if CurrentAttempt(ctx) != 1 {
+		t.Errorf("expected attempt context values to take precedence, got %d", CurrentAttempt(ctx))
+	}
+}
+
+type countingLimiter struct {
+	waits int
+	err   error
+}
+
+func (l *countingLimiter) Wait(context.Context) error {
+	l.waits++
+	return l.err
+}
+
+func TestWithRateLimit(t *testing.T) {
+	t.Run("waits before every attempt", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		limiter := &countingLimiter{}
+		attempts := 0
+		_, _ = ExponentialRetry[int](ctx, 2, time.Millisecond, func() (int, error) {
+			attempts++
+			if limiter.waits != attempts {
+				t.Errorf("attempt %d: expected %d waits, got %d", attempts, attempts, limiter.waits)
+			}
+			return 0, errors.New("fail")
+		}, WithRateLimit(limiter))
+		if limiter.waits != 3 {
+			t.Errorf("expected 3 waits, got %d", limiter.waits)
+		}
+	})
+
+	t.Run("returns the limiter error", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		errLimited := errors.New("rate: wait would exceed context deadline")
+		called := false
+		_, err := ExponentialRetry[int](ctx, 2, time.Millisecond, func() (int, error) {
+			called = true
+			return 1, nil
+		}, WithRateLimit(&countingLimiter{err: errLimited}))
+		if err != errLimited || called {
+			t.Fatalf("expected limiter error without calling fn, got %v (called %v)", err, called)
+		}
+	})
+}
+
+func TestWithTimeBudget(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []RetryOption
+		want uint
+	}{
+		// 10 + 20 + 40 = 70ms fit, adding 80ms does not
+		{"exponential", []RetryOption{WithTimeBudget(100 * time.Millisecond)}, 3},
+		{"exact fit", []RetryOption{WithTimeBudget(70 * time.Millisecond)}, 3},
+		{"smaller max retries kept", []RetryOption{WithMaxRetries(2), WithTimeBudget(time.Hour)}, 2},
+		// 10 + 15 + 15 + 15 + 15 = 70ms
+		{"capped", []RetryOption{WithBackoffCap(15 * time.Millisecond), WithTimeBudget(70 * time.Millisecond)}, 5},
+		// worst case 20 + 40 = 60ms
+		{"jitter", []RetryOption{WithJitter(1), WithTimeBudget(70 * time.Millisecond)}, 2},
+		{"too small for any retry", []RetryOption{WithTimeBudget(5 * time.Millisecond)}, 0},
+		// the budget applies after all options, whatever their order
+		{"order independent", []RetryOption{WithTimeBudget(100 * time.Millisecond), WithBaseBackoff(50 * time.Millisecond)}, 1},
+		// the custom wait counts, not the base backoff: 5 * 1ms fit
+		{"custom wait", []RetryOption{WithBaseBackoff(time.Hour), WithWaitBetweenAttempts(func(uint, error) time.Duration {
+			return time.Millisecond
+		}), WithTimeBudget(5 * time.Millisecond)}, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]RetryOption{WithMaxRetries(10), WithBaseBackoff(10 * time.Millisecond)}, tt.opts...)
+			if got := newConfig(opts).maxRetries; got != tt.want {
+				t.Errorf("expected %d retries, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMaxRetriesZero(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	calls := 0
+	_, err := ExponentialRetry[int](ctx, 0, time.Millisecond, func() (int, error) {
+		calls++
+		return 0, errors.New("fail")
+	})
+	if err == nil || calls != 1 {
+		t.Fatalf("expected exactly one attempt, got %d (%v)", calls, err)
+	}
+}
+
+func TestWithMinMaxRetries(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	ok := func() (int, error) { return 1, nil }
+
+	if _, err := ExponentialRetry[int](ctx, 2, time.Millisecond, ok, WithMinMaxRetries(2)); err != nil {
+		t.Fatalf("expected enough retries to pass, got %v", err)
+	}
+
+	called := false
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic for maxRetries 0 with a minimum of 1")
+		}
+		if called {
+			t.Errorf("expected the check to run before the first attempt")
+		}
+	}()
+	_, _ = ExponentialRetry[int](ctx, 0, time.Millisecond, func() (int, error) {
+		called = true
+		return 1, nil
+	}, WithMinMaxRetries(1))
+}
+
+type attemptLabelKey struct{}
+
+func TestWithAttemptContextFunc(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), requestIDKey{}, "req-42"), time.Second)
+	defer cancel()
+
+	var labels []any
+	var deadlines []bool
+	_, _ = ExponentialRetryCtx[int](ctx, 2, time.Millisecond, func(ctx context.Context) (int, error) {
+		labels = append(labels, ctx.Value(attemptLabelKey{}))
+		_, ok := ctx.Deadline()
+		deadlines = append(deadlines, ok)
+		if ctx.Value(requestIDKey{}) != "req-42" {
+			t.Errorf("expected parent values to be kept")
+		}
+		return 0, errors.New("fail")
+	}, WithInitialAttempt(5), WithAttemptContextFunc(func(parent context.Context, attempt uint) context.Context {
+		return context.WithValue(parent, attemptLabelKey{}, fmt.Sprintf("attempt-%d", attempt))
+	}))
+
+	if fmt.Sprint(labels) != "[attempt-5 attempt-6 attempt-7]" {
+		t.Errorf("expected a label per attempt, got %v", labels)
+	}
+	for i, ok := range deadlines {
+		if !ok {
+			t.Errorf("attempt %d: expected the caller's deadline to be inherited", i)
+		}
+	}
+}
+
+func TestWithMultiplierRational(t *testing.T) {
+	base := 100 * time.Millisecond
+	cfg := newConfig([]RetryOption{WithBaseBackoff(base), WithMultiplierRational(3, 2)})
+
+	want := new(big.Rat).SetInt64(int64(base))
+	for attempt := uint(0); attempt < 10; attempt++ {
+		exact := new(big.Int).Quo(want.Num(), want.Denom()).Int64()
+		if got := cfg.backoff(attempt); got < time.Duration(exact)-1 || got > time.Duration(exact)+1 {
+			t.Errorf("attempt %d: expected %v within 1ns, got %v", attempt, time.Duration(exact), got)
+		}
+		want.Mul(want, big.NewRat(3, 2))
+	}
+}
+
+func TestWithMultiplierRational_Overflow(t *testing.T) {
+	cfg := newConfig([]RetryOption{WithBaseBackoff(time.Second), WithMultiplierRational(3, 2)})
+
+	// 3^40 no longer fits into 64 bits, so the float path takes over
+	f := float64(time.Second) * math.Pow(1.5, 40)
+	if got := cfg.backoff(40); math.Abs(float64(got)-f) > f*1e-12 {
+		t.Errorf("expected about %v, got %v", time.Duration(f), got)
+	}
+	if got := cfg.backoff(200); got != math.MaxInt64 {
+		t.Errorf("expected overflowing backoff to saturate, got %v", got)
+	}
+
+	capped := newConfig([]RetryOption{WithBaseBackoff(time.Second), WithMultiplierRational(3, 2), WithBackoffCap(time.Minute)})
+	if got := capped.backoff(200); got != time.Minute {
+		t.Errorf("expected overflowing backoff to be capped, got %v", got)
+	}
+}
+
+func TestWithMultiplierRational_Invalid(t *testing.T) {
+	for _, frac := range [][2]uint{{1, 0}, {1, 2}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic for %d/%d", frac[0], frac[1])
+				}
+			}()
+			WithMultiplierRational(frac[0], frac[1])
+		}()
+	}
+}
+
+func TestWithRequireConsecutiveSuccesses(t *testing.T) {
+	t.Run("failure resets the count", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		// succeed, fail, then succeed twice in a row
+		outcomes := []error{nil, errors.New("flap"), nil, nil}
+		calls := 0
+		val, err := ExponentialRetry[int](ctx, 5, time.Millisecond, func() (int, error) {
+			calls++
+			return calls * 10, outcomes[calls-1]
+		}, WithRequireConsecutiveSuccesses(2))
+		if err != nil || val != 40 {
+			t.Fatalf("expected the value of the last success 40, got %v, %v", val, err)
+		}
+		if calls != 4 {
+			t.Errorf("expected 4 calls, got %d", calls)
+		}
+	})
+
+	t.Run("attempts run out", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		calls := 0
+		_, err := ExponentialRetry[int](ctx, 2, time.Millisecond, func() (int, error) {
+			calls++
+			if calls == 2 {
+				return 0, errors.New("flap")
+			}
+			return 1, nil
+		}, WithRequireConsecutiveSuccesses(3))
+		if !errors.Is(err, ErrUnconfirmedSuccess) || !errors.Is(err, RetryError{}) {
+			t.Fatalf("expected RetryError wrapping ErrUnconfirmedSuccess, got %v", err)
+		}
+	})
+}
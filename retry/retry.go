@@ -3,39 +3,295 @@ package retry
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
+	"runtime"
+	"runtime/debug"
 	"time"
 )
 
-func ExponentialRetry[T any](ctx context.Context, maxRetries uint, baseBackoff time.Duration, fn func() (T, error)) (T, error) {
-	var zero T
-	_, ok := ctx.Deadline()
+type attemptKey struct{}
+
+type startKey struct{}
+
+// CurrentAttempt returns the zero-based attempt number injected into ctx by
+// ExponentialRetryCtx. It returns 0 when ctx was not created by a retry loop.
+func CurrentAttempt(ctx context.Context) uint {
+	attempt, _ := ctx.Value(attemptKey{}).(uint)
+	return attempt
+}
+
+// ElapsedTime returns how long the retry loop that created ctx has been
+// running, measured from before its first attempt. It returns 0 when ctx was
+// not created by a retry loop.
+func ElapsedTime(ctx context.Context) time.Duration {
+	start, ok := ctx.Value(startKey{}).(time.Time)
 	if !ok {
-		return zero, errors.New("no deadline set by caller")
+		return 0
 	}
+	return time.Since(start)
+}
 
-	for attempt := uint(0); attempt <= maxRetries; attempt++ {
-		result, err := fn()
-		if err == nil {
-			return result, nil
+// ExponentialRetry calls fn until it succeeds or maxRetries retries have
+// failed, doubling the wait after every failure starting at baseBackoff.
+// maxRetries counts the retries after the initial attempt: 0 calls fn exactly
+// once, 3 up to four times. ctx must have a deadline, see WithAllowNoDeadline.
+func ExponentialRetry[T any](ctx context.Context, maxRetries uint, baseBackoff time.Duration, fn func() (T, error), opts ...RetryOption) (T, error) {
+	return ExponentialRetryCtx(ctx, maxRetries, baseBackoff, func(context.Context) (T, error) {
+		return fn()
+	}, opts...)
+}
+
+// ExponentialRetryCtx behaves like ExponentialRetry but hands fn a context
+// carrying the current attempt number, see CurrentAttempt. The context is
+// immutable, so fn may pass it on to goroutines it spawns.
+func ExponentialRetryCtx[T any](ctx context.Context, maxRetries uint, baseBackoff time.Duration, fn func(ctx context.Context) (T, error), opts ...RetryOption) (T, error) {
+	opts = append([]RetryOption{WithMaxRetries(maxRetries), WithBaseBackoff(baseBackoff)}, opts...)
+	return run(ctx, newConfig(opts), fn)
+}
+
+// Retry retries fn, which only reports an error, e.g. for side effects. It is
+// ExponentialRetry without the struct{} result; the number of retries and the
+// base backoff default to DefaultMaxRetries and DefaultBaseBackoff, see
+// WithMaxRetries and WithBaseBackoff.
+func Retry(ctx context.Context, fn func() error, opts ...RetryOption) error {
+	_, err := ExponentialRetry(ctx, DefaultMaxRetries, DefaultBaseBackoff, func() (struct{}, error) {
+		return struct{}{}, fn()
+	}, opts...)
+	return err
+}
+
+// Do retries fn like Retry, in the style of github.com/avast/retry-go. It
+// needs no context and therefore no deadline, and is meant for scripts and
+// tools rather than services, which should use Retry with a deadline.
+func Do(fn func() error, opts ...RetryOption) error {
+	return DoWithContext(context.Background(), fn, opts...)
+}
+
+// DoWithContext is Do with a context that stops the loop when it is done. Like
+// Do, it does not require ctx to have a deadline.
+func DoWithContext(ctx context.Context, fn func() error, opts ...RetryOption) error {
+	return Retry(ctx, fn, append([]RetryOption{WithAllowNoDeadline()}, opts...)...)
+}
+
+// RetryWith retries fn followed by transform, e.g. fetching raw bytes and
+// parsing them, as one attempt: an error from either step is retried. The
+// number of retries and the base backoff default to DefaultMaxRetries and
+// DefaultBaseBackoff, see WithMaxRetries and WithBaseBackoff.
+func RetryWith[T, U any](ctx context.Context, fn func() (T, error), transform func(T) (U, error), opts ...RetryOption) (U, error) {
+	return run(ctx, newConfig(opts), func(context.Context) (U, error) {
+		v, err := fn()
+		if err != nil {
+			var zero U
+			return zero, err
 		}
-		// if we've exhausted retries, return the last error
-		if attempt == maxRetries {
+		return transform(v)
+	})
+}
+
+func run[T any](ctx context.Context, cfg *config, fn func(ctx context.Context) (T, error)) (_ T, finalErr error) {
+	var zero T
+	var attempts uint
+	started := time.Now()
+	ctx = context.WithValue(ctx, startKey{}, started)
+	if len(cfg.after) > 0 {
+		defer func() {
+			for _, hook := range cfg.after {
+				hook(attempts, finalErr)
+			}
+		}()
+	}
+	var errs []error
+	if cfg.accumulate {
+		defer func() {
+			if finalErr == nil || len(errs) == 0 {
+				return
+			}
+			var exhausted *RetryError
+			if errors.As(finalErr, &exhausted) {
+				// LastErr is the last error as annotated by WithWrapErrors
+				errs[len(errs)-1] = exhausted.LastErr
+				exhausted.LastErr = &MultiError{Errors: errs}
+				return
+			}
+			if last := len(errs) - 1; errors.Is(finalErr, errs[last]) {
+				// keep what the loop wrapped the last error in, such as
+				// ErrCircuitTripped
+				errs[last] = finalErr
+			} else {
+				// the loop ended for another reason than the last attempt,
+				// e.g. the context expired while waiting
+				errs = append(errs, finalErr)
+			}
+			finalErr = &MultiError{Errors: errs}
+		}()
+	}
+
+	var extendable *extendableContext
+	var progress int64
+	var lastErr error
+	var successes uint
+	defer func() {
+		if extendable != nil {
+			extendable.release()
+		}
+	}()
+
+	// start checks the deadline and arms the features depending on it. With
+	// WithWarmupAttempt it only runs once the first attempt has failed.
+	start := func() error {
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			if cfg.allowNoDeadline {
+				// without a deadline there is nothing to extend
+				return nil
+			}
+			return ErrNoDeadline
+		}
+		if cfg.progress != nil && cfg.extension > 0 {
+			extendable = newExtendableContext(ctx, deadline)
+			ctx = extendable
+			progress = cfg.progress()
+		}
+		return nil
+	}
+	if !cfg.warmup {
+		if err := start(); err != nil {
 			return zero, err
 		}
-		backoff := baseBackoff * time.Duration(1<<attempt)
-		select {
-		case <-time.After(backoff):
-			// try again
+	}
+
+	// every iteration calls fn first and only waits after it failed, so the
+	// first attempt runs immediately and the backoff is measured from the
+	// end of the failed attempt, not from its start
+	for i := uint(0); i <= cfg.maxRetries; i++ {
+		// attempt is the number reported to fn, hooks and logs; i drives
+		// the retry budget and the backoff
+		attempt := cfg.initialAttempt + i
+		var result T
+		if cfg.limiter != nil {
+			waitCtx := cfg.guaranteed(ctx, i)
+			if err := cfg.limiter.Wait(waitCtx); err != nil {
+				if waitCtx.Err() != nil {
+					return zero, cfg.contextErr(waitCtx)
+				}
+				return zero, err
+			}
+		}
+		err := cfg.checkBreaker()
+		if err == nil {
+			err = cfg.checkDecay(i)
+		}
+		if err == nil {
+			attempts++
+			result, err = attemptOnce(ctx, cfg, i, attempt, fn)
+		}
+		if cfg.dryRun != nil {
+			cfg.dryRun(cfg.label(attempt), err)
+			if err == nil && lastErr != nil {
+				return zero, lastErr
+			}
+			lastErr = err
+		}
+		if err == nil {
+			if successes++; successes >= cfg.requiredSuccesses {
+				return result, nil
+			}
+			if i == cfg.maxRetries {
+				return zero, &RetryError{LastErr: ErrUnconfirmedSuccess, Attempts: attempts, TotalDuration: time.Since(started)}
+			}
+			// confirm the success after the base backoff
+			if !cfg.sleep(cfg.guaranteed(ctx, i+1), cfg.baseBackoff) {
+				return zero, cfg.contextErr(ctx)
+			}
 			continue
-		case <-ctx.Done():
-			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
-				slog.Info("deadline exceeded")
-			} else {
-				slog.Info("canceled or timeout")
+		}
+		successes = 0
+		if cfg.accumulate {
+			errs = append(errs, err)
+		}
+		if cfg.tripped(err) {
+			return zero, fmt.Errorf("%w: %w", ErrCircuitTripped, err)
+		}
+		// if we've exhausted retries, return the last error
+		if i == cfg.maxRetries {
+			elapsed := time.Since(started)
+			return zero, &RetryError{LastErr: cfg.wrapErr(err, i, elapsed), Attempts: attempts, TotalDuration: elapsed}
+		}
+		if cfg.permanent(err) {
+			return zero, cfg.wrapErr(err, i, time.Since(started))
+		}
+		if i == 0 && cfg.warmup {
+			if err := start(); err != nil {
+				return zero, err
+			}
+		}
+		if extendable != nil {
+			current := cfg.progress()
+			if current-progress > cfg.progressThreshold {
+				extendable.extend(cfg.extension)
 			}
-			return zero, ctx.Err()
+			progress = current
+		}
+		// only compute the backoff once we know there is another attempt
+		// and the context is still alive to make it
+		waitCtx := cfg.guaranteed(ctx, i+1)
+		if waitCtx.Err() == nil {
+			backoff := cfg.wait(i, err)
+			cfg.logRetry(cfg.label(attempt), err, backoff)
+			for _, hook := range cfg.onRetry {
+				hook(cfg.label(attempt), err, backoff)
+			}
+			endSleep := cfg.startSleepSpan(waitCtx, backoff)
+			slept := cfg.sleep(waitCtx, backoff)
+			endSleep()
+			if slept {
+				// yield before the next attempt, so goroutines starved
+				// while we slept, e.g. with GOMAXPROCS=1, get to run
+				runtime.Gosched()
+				continue
+			}
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			cfg.logInfo("deadline exceeded", slog.Uint64("attempt", uint64(cfg.label(attempt))))
+		} else {
+			cfg.logInfo("canceled or timeout", slog.Uint64("attempt", uint64(cfg.label(attempt))))
 		}
+		return zero, cfg.contextErr(ctx)
 	}
 	return zero, errors.New("exponential retry failed")
 }
+
+// attemptOnce runs the i-th attempt with its own derived context.
+func attemptOnce[T any](ctx context.Context, cfg *config, i, attempt uint, fn func(ctx context.Context) (T, error)) (T, error) {
+	attemptCtx, cancelAttempt := cfg.attemptContext(context.WithValue(cfg.guaranteed(ctx, i), attemptKey{}, attempt), i)
+	defer cancelAttempt()
+	attemptCtx, endSpan := cfg.startAttemptSpan(attemptCtx, cfg.label(attempt))
+	if cfg.propagate {
+		attemptCtx = valuesFrom{Context: attemptCtx, caller: ctx}
+	}
+	if !cfg.acquire(attemptCtx) {
+		var zero T
+		err := cfg.contextErr(attemptCtx)
+		endSpan(err)
+		return zero, err
+	}
+	defer cfg.release()
+	result, err := callAttempt(attemptCtx, cfg, fn)
+	endSpan(err)
+	return result, err
+}
+
+// callAttempt invokes fn once, converting a panic into an error when
+// WithRecoverPanics is set.
+func callAttempt[T any](ctx context.Context, cfg *config, fn func(ctx context.Context) (T, error)) (result T, err error) {
+	if cfg.recoverPanics {
+		defer func() {
+			if r := recover(); r != nil {
+				var zero T
+				result, err = zero, fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+			}
+		}()
+	}
+	return fn(ctx)
+}
@@ -3,6 +3,11 @@ package retry
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 )
@@ -59,8 +64,139 @@ func TestExponentialRetry_ExhaustRetries(t *testing.T) {
 	if err == nil {
 		t.Fatalf("expected error, got nil")
 	}
-	if err.Error() != "permanent failure" {
-		t.Fatalf("expected last error 'permanent failure', got %v", err)
+	if !errors.Is(err, RetryError{}) {
+		t.Fatalf("expected RetryError, got %T", err)
+	}
+	var exhausted *RetryError
+	if !errors.As(err, &exhausted) || exhausted.Attempts != 3 || exhausted.TotalDuration <= 0 {
+		t.Fatalf("expected 3 attempts and a duration, got %+v", exhausted)
+	}
+	if exhausted.LastErr.Error() != "permanent failure" {
+		t.Fatalf("expected last error 'permanent failure', got %v", exhausted.LastErr)
+	}
+}
+
+func TestExponentialRetry_FirstErrorNotExhausted(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	errFatal := errors.New("fatal")
+	_, err := ExponentialRetry[int](ctx, 2, time.Millisecond, func() (int, error) {
+		return 0, errFatal
+	}, WithRetryIf(func(error) bool { return false }))
+	if err != errFatal {
+		t.Fatalf("expected the bare error, got %v", err)
+	}
+	if errors.Is(err, RetryError{}) {
+		t.Fatalf("expected error not to be a RetryError")
+	}
+}
+
+func TestRetry(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	calls := 0
+	err := Retry(ctx, func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("fail")
+		}
+		return nil
+	}, WithBaseBackoff(time.Millisecond))
+	if err != nil || calls != 3 {
+		t.Fatalf("expected success after 3 calls, got %v after %d", err, calls)
+	}
+
+	calls = 0
+	err = Retry(ctx, func() error {
+		calls++
+		return errors.New("fail")
+	}, WithMaxRetries(1), WithBaseBackoff(time.Millisecond))
+	if !errors.Is(err, RetryError{}) || calls != 2 {
+		t.Fatalf("expected exhausted retries after 2 calls, got %v after %d", err, calls)
+	}
+}
+
+func TestDo(t *testing.T) {
+	calls := 0
+	err := Do(func() error {
+		calls++
+		if calls < 2 {
+			return errors.New("fail")
+		}
+		return nil
+	}, WithBaseBackoff(time.Millisecond))
+	if err != nil || calls != 2 {
+		t.Fatalf("expected success after 2 calls without a deadline, got %v after %d", err, calls)
+	}
+}
+
+func TestDoWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := DoWithContext(ctx, func() error {
+		calls++
+		cancel()
+		return errors.New("fail")
+	}, WithMaxRetries(5), WithBaseBackoff(time.Millisecond))
+	if !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Fatalf("expected cancellation to stop the loop after 1 call, got %v after %d", err, calls)
+	}
+}
+
+func TestRetryWith(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	fetches, parses := 0, 0
+	got, err := RetryWith(ctx, func() (string, error) {
+		fetches++
+		if fetches == 1 {
+			return "", errors.New("connection reset")
+		}
+		return fmt.Sprintf("%d", fetches*10), nil
+	}, func(raw string) (int, error) {
+		parses++
+		if raw == "20" {
+			return 0, errors.New("truncated payload")
+		}
+		return strconv.Atoi(raw)
+	}, WithBaseBackoff(time.Millisecond))
+
+	if err != nil || got != 30 {
+		t.Fatalf("expected 30, got %d, %v", got, err)
+	}
+	if fetches != 3 || parses != 2 {
+		t.Errorf("expected 3 fetches and 2 parses, got %d and %d", fetches, parses)
+	}
+}
+
+func TestExponentialRetry_BackoffStartsAfterAttempt(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	const work, backoff = 20 * time.Millisecond, 30 * time.Millisecond
+	var starts, ends []time.Time
+	called := time.Now()
+	_, _ = ExponentialRetry[int](ctx, 1, backoff, func() (int, error) {
+		starts = append(starts, time.Now())
+		time.Sleep(work)
+		ends = append(ends, time.Now())
+		return 0, errors.New("fail")
+	})
+
+	if len(starts) != 2 {
+		t.Fatalf("expected 2 attempts, got %d", len(starts))
+	}
+	// no delay precedes the first attempt
+	if d := starts[0].Sub(called); d >= backoff/2 {
+		t.Errorf("expected first attempt to start immediately, started after %v", d)
+	}
+	// the backoff runs after the failed attempt returned, not in parallel
+	// with it
+	if d := starts[1].Sub(ends[0]); d < backoff {
+		t.Errorf("expected at least %v between the first failure and the retry, got %v", backoff, d)
 	}
 }
 
@@ -69,11 +205,22 @@ func TestExponentialRetry_NoDeadline(t *testing.T) {
 	_, err := ExponentialRetry[int](context.Background(), 2, 1*time.Millisecond, func() (int, error) {
 		return 0, nil
 	})
-	if err == nil {
-		t.Fatalf("expected error when no deadline is set")
+	if !errors.Is(err, ErrNoDeadline) {
+		t.Fatalf("expected ErrNoDeadline, got %v", err)
 	}
-	if err.Error() != "no deadline set by caller" {
-		t.Fatalf("unexpected error message: %v", err)
+}
+
+func TestWithAllowNoDeadline(t *testing.T) {
+	attempts := 0
+	val, err := ExponentialRetry[int](context.Background(), 2, time.Millisecond, func() (int, error) {
+		attempts++
+		if attempts < 3 {
+			return 0, errors.New("fail")
+		}
+		return 7, nil
+	}, WithAllowNoDeadline())
+	if err != nil || val != 7 {
+		t.Fatalf("expected 7 without a deadline, got %v, %v", val, err)
 	}
 }
 
@@ -94,3 +241,193 @@ func TestExponentialRetry_ContextDeadlineExceeded(t *testing.T) {
 		t.Fatalf("expected DeadlineExceeded, got %v", err)
 	}
 }
+
+func TestExponentialRetry_ContextCause(t *testing.T) {
+	fail := func() (int, error) { return 0, errors.New("transient") }
+
+	t.Run("cancel cause", func(t *testing.T) {
+		errShutdown := errors.New("server shutting down")
+		parent, cancel := context.WithCancelCause(context.Background())
+		ctx, stop := context.WithTimeout(parent, time.Second)
+		defer stop()
+		_, err := ExponentialRetry[int](ctx, 5, time.Second, func() (int, error) {
+			// cancelled while the loop waits for the next attempt
+			cancel(errShutdown)
+			return fail()
+		})
+		if !errors.Is(err, errShutdown) {
+			t.Fatalf("expected %v, got %v", errShutdown, err)
+		}
+	})
+
+	t.Run("timeout cause", func(t *testing.T) {
+		errSlow := errors.New("upstream too slow")
+		ctx, cancel := context.WithTimeoutCause(context.Background(), 10*time.Millisecond, errSlow)
+		defer cancel()
+		_, err := ExponentialRetry[int](ctx, 5, time.Second, fail)
+		if !errors.Is(err, errSlow) {
+			t.Fatalf("expected %v, got %v", errSlow, err)
+		}
+	})
+
+	t.Run("cause while rate limited", func(t *testing.T) {
+		errSlow := errors.New("upstream too slow")
+		ctx, cancel := context.WithTimeoutCause(context.Background(), 10*time.Millisecond, errSlow)
+		defer cancel()
+		limiter := waitFunc(func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		_, err := ExponentialRetry[int](ctx, 5, time.Second, fail, WithRateLimit(limiter))
+		if !errors.Is(err, errSlow) {
+			t.Fatalf("expected %v, got %v", errSlow, err)
+		}
+	})
+
+	t.Run("cause while waiting for the semaphore", func(t *testing.T) {
+		errSlow := errors.New("upstream too slow")
+		ctx, cancel := context.WithTimeoutCause(context.Background(), 10*time.Millisecond, errSlow)
+		defer cancel()
+		sem := make(chan struct{}, 1)
+		sem <- struct{}{} // held by someone else
+		_, err := ExponentialRetry[int](ctx, 0, time.Second, fail, WithAttemptSemaphore(sem))
+		if !errors.Is(err, errSlow) {
+			t.Fatalf("expected %v, got %v", errSlow, err)
+		}
+	})
+}
+
+// waitFunc adapts a function to the limiter of WithRateLimit.
+type waitFunc func(context.Context) error
+
+func (f waitFunc) Wait(ctx context.Context) error {
+	return f(ctx)
+}
+
+func TestExponentialRetryCtx_CurrentAttempt(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var seen []uint
+	fn := func(ctx context.Context) (int, error) {
+		// read the attempt from a spawned goroutine to exercise concurrent access
+		ch := make(chan uint)
+		go func() { ch <- CurrentAttempt(ctx) }()
+		seen = append(seen, <-ch)
+		if len(seen) < 3 {
+			return 0, errors.New("fail")
+		}
+		return 1, nil
+	}
+
+	_, err := ExponentialRetryCtx[int](ctx, 5, time.Millisecond, fn)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(seen) != 3 || seen[0] != 0 || seen[1] != 1 || seen[2] != 2 {
+		t.Fatalf("expected attempts [0 1 2], got %v", seen)
+	}
+}
+
+func TestCurrentAttempt_OutsideRetry(t *testing.T) {
+	if got := CurrentAttempt(context.Background()); got != 0 {
+		t.Fatalf("expected 0 outside a retry context, got %d", got)
+	}
+}
+
+func TestElapsedTime(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var elapsed []time.Duration
+	_, _ = ExponentialRetryCtx[int](ctx, 3, 2*time.Millisecond, func(ctx context.Context) (int, error) {
+		elapsed = append(elapsed, ElapsedTime(ctx))
+		return 0, errors.New("fail")
+	})
+
+	if len(elapsed) != 4 {
+		t.Fatalf("expected 4 attempts, got %d", len(elapsed))
+	}
+	for i := 1; i < len(elapsed); i++ {
+		if elapsed[i] <= elapsed[i-1] {
+			t.Fatalf("expected elapsed time to increase, got %v", elapsed)
+		}
+	}
+	// the backoffs add up to 2+4+8ms before the last attempt
+	if elapsed[3] < 14*time.Millisecond {
+		t.Errorf("expected at least 14ms before the last attempt, got %v", elapsed[3])
+	}
+}
+
+func TestElapsedTime_OutsideRetry(t *testing.T) {
+	if got := ElapsedTime(context.Background()); got != 0 {
+		t.Fatalf("expected 0 outside a retry context, got %v", got)
+	}
+}
+
+func TestExponentialRetry_ExpiredContextSkipsBackoff(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	hooked := false
+	_, err := ExponentialRetry[int](ctx, 3, time.Hour, func() (int, error) {
+		cancel()
+		return 0, errors.New("fail")
+	}, WithOnRetry(func(uint, error, time.Duration) { hooked = true }))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected Canceled, got %v", err)
+	}
+	if hooked {
+		t.Errorf("expected no backoff to be computed for a context that is already done")
+	}
+}
+
+func BenchmarkExponentialRetry_ExpiredContext(b *testing.B) {
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer slog.SetDefault(prev)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	cancel()
+	errFail := errors.New("fail")
+	fn := func() (int, error) { return 0, errFail }
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, _ = ExponentialRetry[int](ctx, 3, time.Millisecond, fn)
+	}
+}
+
+func BenchmarkExponentialRetry_Backoff(b *testing.B) {
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer slog.SetDefault(prev)
+
+	errFail := errors.New("fail")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	fn := func() func() (int, error) {
+		calls := 0
+		return func() (int, error) {
+			calls++
+			if calls < 3 {
+				return 0, errFail
+			}
+			return 0, nil
+		}
+	}
+
+	b.Run("time.After", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = ExponentialRetry[int](ctx, 3, time.Nanosecond, fn())
+		}
+	})
+	b.Run("pool", func(b *testing.B) {
+		pool := &sync.Pool{}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = ExponentialRetry[int](ctx, 3, time.Nanosecond, fn(), WithTimerPool(pool))
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package retry
+
+import (
+	"context"
+	"time"
+)
+
+// RetryEvent reports the outcome of a single attempt of
+// ExponentialRetryStream. The last event on the channel has Done set and
+// carries the final result of the retry loop.
+type RetryEvent[T any] struct {
+	Attempt uint
+	Value   T
+	Err     error
+	Done    bool
+}
+
+// ExponentialRetryStream runs the retry loop in a goroutine and reports every
+// attempt on the returned channel, which is closed once the loop ends. The
+// number of retries and the base backoff default to DefaultMaxRetries and
+// DefaultBaseBackoff and can be changed with WithMaxRetries and
+// WithBaseBackoff.
+//
+// Callers that stop consuming should cancel ctx; once ctx is done, attempts
+// not yet taken are dropped to make room for the final event, so the goroutine
+// never outlives the context deadline and a slow consumer still gets the
+// result.
+func ExponentialRetryStream[T any](ctx context.Context, fn func() (T, error), opts ...RetryOption) <-chan RetryEvent[T] {
+	events := make(chan RetryEvent[T], 1)
+	send := func(ev RetryEvent[T]) bool {
+		// we are the only sender, so an empty buffer guarantees the send
+		// does not block
+		if len(events) == 0 {
+			events <- ev
+			return true
+		}
+		select {
+		case events <- ev:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	cfg := newConfig(opts)
+	var last RetryEvent[T]
+	cfg.onRetry = append(cfg.onRetry, func(attempt uint, err error, _ time.Duration) {
+		send(RetryEvent[T]{Attempt: attempt, Value: last.Value, Err: err})
+	})
+
+	go func() {
+		defer close(events)
+		value, err := run(ctx, cfg, func(ctx context.Context) (T, error) {
+			last.Attempt = cfg.label(CurrentAttempt(ctx))
+			v, err := fn()
+			last.Value = v
+			return v, err
+		})
+		final := RetryEvent[T]{Attempt: last.Attempt, Value: value, Err: err, Done: true}
+		if !send(final) {
+			// the buffer holds an attempt the consumer has not taken yet;
+			// replace it, the final event matters more
+			select {
+			case <-events:
+			default:
+			}
+			events <- final
+		}
+	}()
+	return events
+}
@@ -0,0 +1,102 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestExponentialRetryStream(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	attempts := 0
+	fn := func() (int, error) {
+		attempts++
+		if attempts < 3 {
+			return 0, errors.New("fail")
+		}
+		return 7, nil
+	}
+
+	var events []RetryEvent[int]
+	for ev := range ExponentialRetryStream(ctx, fn, WithMaxRetries(5), WithBaseBackoff(time.Millisecond)) {
+		events = append(events, ev)
+	}
+
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d: %+v", len(events), events)
+	}
+	for i, ev := range events[:2] {
+		if ev.Attempt != uint(i) || ev.Err == nil || ev.Done {
+			t.Errorf("event %d: expected failed, not done attempt %d, got %+v", i, i, ev)
+		}
+	}
+	final := events[2]
+	if !final.Done || final.Err != nil || final.Value != 7 || final.Attempt != 2 {
+		t.Errorf("expected final done event with value 7 at attempt 2, got %+v", final)
+	}
+}
+
+func TestExponentialRetryStream_Exhausted(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var last RetryEvent[int]
+	n := 0
+	for ev := range ExponentialRetryStream(ctx, func() (int, error) {
+		return 0, errors.New("permanent failure")
+	}, WithMaxRetries(2), WithBaseBackoff(time.Millisecond)) {
+		last = ev
+		n++
+	}
+
+	if n != 3 {
+		t.Fatalf("expected 3 events, got %d", n)
+	}
+	if !last.Done || last.Err == nil || errors.Unwrap(last.Err).Error() != "permanent failure" {
+		t.Fatalf("expected final event with last error, got %+v", last)
+	}
+}
+
+func TestExponentialRetryStream_SlowConsumer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	events := ExponentialRetryStream(ctx, func() (int, error) {
+		return 0, errors.New("fail")
+	}, WithMaxRetries(100), WithBaseBackoff(time.Millisecond))
+
+	var last RetryEvent[int]
+	for ev := range events {
+		// still reading, but too slow to keep up before ctx expires
+		time.Sleep(100 * time.Millisecond)
+		last = ev
+	}
+	if !last.Done || !errors.Is(last.Err, context.DeadlineExceeded) {
+		t.Fatalf("expected final event with the deadline error, got %+v", last)
+	}
+}
+
+func TestExponentialRetryStream_NoLeakWhenConsumerStops(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	events := ExponentialRetryStream(ctx, func() (int, error) {
+		return 0, errors.New("fail")
+	}, WithMaxRetries(10), WithBaseBackoff(time.Millisecond))
+
+	// read a single event, then walk away
+	<-events
+	cancel()
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("retry goroutine leaked: %d goroutines, expected %d", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,59 @@
+package retry
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WithTracing starts a child span named "retry.attempt" of the span in the
+// caller's context for every attempt. A failed attempt records its error on
+// the span. The span context is passed on to fn by ExponentialRetryCtx.
+func WithTracing(tracer trace.Tracer) RetryOption {
+	return func(c *config) {
+		c.tracer = tracer
+	}
+}
+
+// startAttemptSpan starts the span for an attempt when tracing is enabled.
+// The returned function ends the span with the outcome of the attempt.
+func (c *config) startAttemptSpan(ctx context.Context, attempt uint) (context.Context, func(error)) {
+	if c.tracer == nil {
+		return ctx, func(error) {}
+	}
+	attrs := []attribute.KeyValue{attribute.Int64("retry.attempt", int64(attempt))}
+	if c.operation != "" {
+		attrs = append(attrs, attribute.String("retry.operation", c.operation))
+	}
+	ctx, span := c.tracer.Start(ctx, "retry.attempt", trace.WithAttributes(attrs...))
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// WithSleepSpan starts a child span named "retry.backoff" of the span in the
+// caller's context for every backoff sleep, so the time spent waiting between
+// attempts shows up in traces. The span carries the planned delay as
+// "retry.delay_ms" and ends when the sleep is over or cut short.
+func WithSleepSpan(tracer trace.Tracer) RetryOption {
+	return func(c *config) {
+		c.sleepTracer = tracer
+	}
+}
+
+// startSleepSpan starts the span for a backoff of d when WithSleepSpan is
+// set. The returned function ends it.
+func (c *config) startSleepSpan(ctx context.Context, d time.Duration) func() {
+	if c.sleepTracer == nil {
+		return func() {}
+	}
+	_, span := c.sleepTracer.Start(ctx, "retry.backoff", trace.WithAttributes(attribute.Int64("retry.delay_ms", d.Milliseconds())))
+	return func() { span.End() }
+}
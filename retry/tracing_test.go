@@ -0,0 +1,124 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// recordingTracer hands out recordingSpans so tests can inspect them.
+type recordingTracer struct {
+	embedded.Tracer
+	spans []*recordingSpan
+}
+
+type recordingSpan struct {
+	noop.Span
+	name   string
+	parent trace.Span
+	errs   []error
+	status codes.Code
+	ended  bool
+	attrs  []attribute.KeyValue
+}
+
+func (r *recordingTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	cfg := trace.NewSpanStartConfig(opts...)
+	span := &recordingSpan{name: name, parent: trace.SpanFromContext(ctx), attrs: cfg.Attributes()}
+	r.spans = append(r.spans, span)
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+func (s *recordingSpan) RecordError(err error, _ ...trace.EventOption) { s.errs = append(s.errs, err) }
+func (s *recordingSpan) SetStatus(code codes.Code, _ string)           { s.status = code }
+func (s *recordingSpan) End(...trace.SpanEndOption)                    { s.ended = true }
+
+func TestWithTracing(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	parent := &recordingSpan{name: "parent"}
+	ctx = trace.ContextWithSpan(ctx, parent)
+
+	tracer := &recordingTracer{}
+	attempts := 0
+	_, err := ExponentialRetryCtx[int](ctx, 3, time.Millisecond, func(ctx context.Context) (int, error) {
+		attempts++
+		if trace.SpanFromContext(ctx) != tracer.spans[len(tracer.spans)-1] {
+			t.Errorf("expected fn to receive the attempt span")
+		}
+		if attempts < 2 {
+			return 0, errors.New("fail")
+		}
+		return 1, nil
+	}, WithTracing(tracer))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(tracer.spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(tracer.spans))
+	}
+	for i, span := range tracer.spans {
+		if span.name != "retry.attempt" || !span.ended || span.parent != trace.Span(parent) {
+			t.Errorf("span %d: expected ended child span 'retry.attempt', got %+v", i, span)
+		}
+	}
+	if len(tracer.spans[0].errs) != 1 || tracer.spans[0].status != codes.Error {
+		t.Errorf("expected failed attempt to record its error")
+	}
+	if len(tracer.spans[1].errs) != 0 || tracer.spans[1].status == codes.Error {
+		t.Errorf("expected successful attempt to record no error")
+	}
+}
+
+func TestWithTracing_Noop(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	val, err := ExponentialRetry[int](ctx, 1, time.Millisecond, func() (int, error) {
+		return 3, nil
+	}, WithTracing(noop.NewTracerProvider().Tracer("test")))
+	if err != nil || val != 3 {
+		t.Fatalf("expected 3, got %v, %v", val, err)
+	}
+}
+
+func TestWithSleepSpan(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	parent := &recordingSpan{name: "parent"}
+	ctx = trace.ContextWithSpan(ctx, parent)
+
+	tracer := &recordingTracer{}
+	calls := 0
+	_, err := ExponentialRetry[int](ctx, 2, 10*time.Millisecond, func() (int, error) {
+		calls++
+		if calls < 3 {
+			return 0, errors.New("fail")
+		}
+		return 1, nil
+	}, WithSleepSpan(tracer))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(tracer.spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(tracer.spans))
+	}
+	for i, span := range tracer.spans {
+		if span.name != "retry.backoff" || !span.ended || span.parent != trace.Span(parent) {
+			t.Errorf("span %d: unexpected %+v", i, span)
+		}
+		want := attribute.Int64("retry.delay_ms", (10 * time.Millisecond << i).Milliseconds())
+		if len(span.attrs) != 1 || span.attrs[0] != want {
+			t.Errorf("span %d: expected attributes [%v], got %v", i, want, span.attrs)
+		}
+	}
+}
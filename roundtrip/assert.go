@@ -0,0 +1,71 @@
+package roundtrip
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// AssertNoRequestsTo fails the test if any logged request URL contains
+// urlSubstring.
+func (srt *TestingRoundTripper) AssertNoRequestsTo(t testing.TB, urlSubstring string) {
+	t.Helper()
+	for i, req := range srt.Requests() {
+		if strings.Contains(req.URL.String(), urlSubstring) {
+			t.Errorf("request %d to %s matches %q, expected no requests", i, req.URL, urlSubstring)
+		}
+	}
+}
+
+// AssertBasicAuth fails the test for every logged request that does not carry
+// basic auth credentials username and password.
+func (srt *TestingRoundTripper) AssertBasicAuth(t testing.TB, username, password string) {
+	t.Helper()
+	for i, req := range srt.Requests() {
+		user, pass, ok := req.BasicAuth()
+		switch {
+		case !ok:
+			t.Errorf("request %d to %s has no basic auth", i, req.URL)
+		case user != username || pass != password:
+			t.Errorf("request %d to %s has basic auth for %q, expected %q with the configured password", i, req.URL, user, username)
+		}
+	}
+}
+
+// AssertBearerToken fails the test unless the n-th logged request, counting
+// from 0, carries the bearer token token.
+func (srt *TestingRoundTripper) AssertBearerToken(t testing.TB, n int, token string) {
+	t.Helper()
+	req, ok := srt.nthRequest(t, n)
+	if !ok {
+		return
+	}
+	if !WithBearerToken(token)(req) {
+		t.Errorf("request %d to %s has Authorization %q, expected bearer token %q", n, req.URL, req.Header.Get("Authorization"), token)
+	}
+}
+
+// nthRequest returns the n-th logged request, failing the test if there is
+// none.
+func (srt *TestingRoundTripper) nthRequest(t testing.TB, n int) (*http.Request, bool) {
+	t.Helper()
+	requests := srt.Requests()
+	if n < 0 || n >= len(requests) {
+		t.Errorf("expected request %d, got %d requests", n, len(requests))
+		return nil, false
+	}
+	return requests[n], true
+}
+
+// AssertNthRequestIdempotencyKey fails the test unless the n-th logged
+// request, counting from 0, has the Idempotency-Key wantKey.
+func (srt *TestingRoundTripper) AssertNthRequestIdempotencyKey(t testing.TB, n int, wantKey string) {
+	t.Helper()
+	req, ok := srt.nthRequest(t, n)
+	if !ok {
+		return
+	}
+	if got := req.Header.Get("Idempotency-Key"); got != wantKey {
+		t.Errorf("request %d to %s has Idempotency-Key %q, expected %q", n, req.URL, got, wantKey)
+	}
+}
@@ -0,0 +1,139 @@
+package roundtrip
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// recordingTB captures failures instead of failing the surrounding test.
+type recordingTB struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestTestingRoundTripper_AssertNoRequestsTo(t *testing.T) {
+	trt := &TestingRoundTripper{}
+	trt.WithMockResponses([]*http.Response{NewMockResponse(), NewMockResponse()})
+	client := &http.Client{Transport: trt}
+	_, _ = client.Get("https://example.com/cache")
+	_, _ = client.Get("https://example.com/fast")
+
+	t.Run("passes when URL was never called", func(t *testing.T) {
+		rec := &recordingTB{TB: t}
+		trt.AssertNoRequestsTo(rec, "/slow")
+		if len(rec.errors) != 0 {
+			t.Errorf("expected no failures, got %v", rec.errors)
+		}
+	})
+
+	t.Run("fails when URL was called", func(t *testing.T) {
+		rec := &recordingTB{TB: t}
+		trt.AssertNoRequestsTo(rec, "/fast")
+		if len(rec.errors) != 1 {
+			t.Errorf("expected 1 failure, got %v", rec.errors)
+		}
+	})
+}
+
+func TestTestingRoundTripper_AssertBasicAuth(t *testing.T) {
+	trt := &TestingRoundTripper{}
+	trt.WithMockResponses([]*http.Response{NewMockResponse(), NewMockResponse(), NewMockResponse()})
+	client := &http.Client{Transport: trt}
+
+	req, _ := http.NewRequest("GET", "https://example.com/ok", nil)
+	req.SetBasicAuth("gopher", "secret")
+	_, _ = client.Do(req)
+
+	rec := &recordingTB{TB: t}
+	trt.AssertBasicAuth(rec, "gopher", "secret")
+	if len(rec.errors) != 0 {
+		t.Errorf("expected no failures, got %v", rec.errors)
+	}
+
+	req, _ = http.NewRequest("GET", "https://example.com/wrong", nil)
+	req.SetBasicAuth("gopher", "guess")
+	_, _ = client.Do(req)
+	_, _ = client.Get("https://example.com/missing")
+
+	rec = &recordingTB{TB: t}
+	trt.AssertBasicAuth(rec, "gopher", "secret")
+	if len(rec.errors) != 2 {
+		t.Fatalf("expected 2 failures, got %v", rec.errors)
+	}
+	for _, msg := range rec.errors {
+		if strings.Contains(msg, "guess") {
+			t.Errorf("expected the password not to be reported, got %q", msg)
+		}
+	}
+}
+
+func TestTestingRoundTripper_AssertBearerToken(t *testing.T) {
+	trt := &TestingRoundTripper{}
+	trt.WithMockResponses([]*http.Response{NewMockResponse(), NewMockResponse()})
+	client := &http.Client{Transport: trt}
+
+	req, _ := http.NewRequest("GET", "https://example.com/me", nil)
+	req.Header.Set("Authorization", "Bearer abc123")
+	_, _ = client.Do(req)
+	_, _ = client.Get("https://example.com/anonymous")
+
+	tests := []struct {
+		name       string
+		n          int
+		token      string
+		wantErrors int
+	}{
+		{"matching token", 0, "abc123", 0},
+		{"wrong token", 0, "xyz", 1},
+		{"missing header", 1, "abc123", 1},
+		{"no such request", 2, "abc123", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recordingTB{TB: t}
+			trt.AssertBearerToken(rec, tt.n, tt.token)
+			if len(rec.errors) != tt.wantErrors {
+				t.Errorf("expected %d failures, got %v", tt.wantErrors, rec.errors)
+			}
+		})
+	}
+}
+
+func TestTestingRoundTripper_AssertNthRequestIdempotencyKey(t *testing.T) {
+	trt := &TestingRoundTripper{}
+	trt.WithMockResponses([]*http.Response{NewMockResponse(), NewMockResponse()})
+	client := &http.Client{Transport: trt}
+
+	req, _ := http.NewRequest("POST", "https://example.com/payments", nil)
+	req.Header.Set("Idempotency-Key", "key-1")
+	_, _ = client.Do(req)
+	_, _ = client.Post("https://example.com/payments", "text/plain", nil)
+
+	tests := []struct {
+		name       string
+		n          int
+		key        string
+		wantErrors int
+	}{
+		{"matching key", 0, "key-1", 0},
+		{"other key", 0, "key-2", 1},
+		{"missing key", 1, "key-1", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recordingTB{TB: t}
+			trt.AssertNthRequestIdempotencyKey(rec, tt.n, tt.key)
+			if len(rec.errors) != tt.wantErrors {
+				t.Errorf("expected %d failures, got %v", tt.wantErrors, rec.errors)
+			}
+		})
+	}
+}
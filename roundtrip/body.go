@@ -0,0 +1,37 @@
+package roundtrip
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// safeBody is an io.ReadCloser that can be closed any number of times and
+// keeps returning io.EOF once it has been closed.
+type safeBody struct {
+	mu     sync.Mutex
+	r      *bytes.Reader
+	closed bool
+}
+
+// SafeBody returns a response body backed by body that is safe to close more
+// than once and to read from after closing.
+func SafeBody(body []byte) io.ReadCloser {
+	return &safeBody{r: bytes.NewReader(body)}
+}
+
+func (b *safeBody) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return 0, io.EOF
+	}
+	return b.r.Read(p)
+}
+
+func (b *safeBody) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	return nil
+}
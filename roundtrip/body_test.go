@@ -0,0 +1,39 @@
+package roundtrip
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestSafeBody(t *testing.T) {
+	t.Run("reads the full body", func(t *testing.T) {
+		b, err := io.ReadAll(SafeBody([]byte("hello")))
+		if err != nil {
+			t.Fatalf("reading body: %v", err)
+		}
+		if string(b) != "hello" {
+			t.Errorf("expected 'hello', got '%s'", string(b))
+		}
+	})
+
+	t.Run("can be closed multiple times", func(t *testing.T) {
+		body := SafeBody([]byte("hello"))
+		if err := body.Close(); err != nil {
+			t.Fatalf("first close: %v", err)
+		}
+		if err := body.Close(); err != nil {
+			t.Fatalf("second close: %v", err)
+		}
+	})
+
+	t.Run("returns EOF after close", func(t *testing.T) {
+		body := SafeBody([]byte("hello"))
+		_ = body.Close()
+
+		n, err := body.Read(make([]byte, 5))
+		if n != 0 || !errors.Is(err, io.EOF) {
+			t.Errorf("expected (0, EOF), got (%d, %v)", n, err)
+		}
+	})
+}
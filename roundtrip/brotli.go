@@ -0,0 +1,31 @@
+package roundtrip
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	// the standard library has no Brotli encoder
+	"github.com/andybalholm/brotli"
+)
+
+// WithBrotliBody sets the body to data compressed with Brotli and marks it
+// with Content-Encoding: br. The length of the encoded body is left unknown,
+// as it would be for a streamed response.
+func WithBrotliBody(data []byte) func(*http.Response) {
+	return func(r *http.Response) {
+		var buf bytes.Buffer
+		w := brotli.NewWriter(&buf)
+		// writes to a bytes.Buffer cannot fail
+		_, _ = w.Write(data)
+		_ = w.Close()
+
+		if r.Header == nil {
+			r.Header = make(http.Header)
+		}
+		r.Header.Set("Content-Encoding", "br")
+		r.Header.Del("Content-Length")
+		r.ContentLength = -1
+		setBody(r, io.NopCloser(&buf))
+	}
+}
@@ -0,0 +1,44 @@
+package roundtrip
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestWithBrotliBody(t *testing.T) {
+	data := bytes.Repeat([]byte("compress me "), 100)
+	trt := &TestingRoundTripper{}
+	trt.AddMockResponse(NewMockResponse(WithBody([]byte("plain")), WithBrotliBody(data)))
+
+	client := &http.Client{Transport: trt}
+	resp, err := client.Get("https://example.com/")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+
+	if resp.Header.Get("Content-Encoding") != "br" {
+		t.Errorf("expected Content-Encoding 'br', got '%s'", resp.Header.Get("Content-Encoding"))
+	}
+	if resp.ContentLength != -1 {
+		t.Errorf("expected unknown content length, got %d", resp.ContentLength)
+	}
+
+	compressed, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading body: %v", err)
+	}
+	if len(compressed) >= len(data) {
+		t.Errorf("expected compressed body to be smaller than %d bytes, got %d", len(data), len(compressed))
+	}
+	decoded, err := io.ReadAll(brotli.NewReader(bytes.NewReader(compressed)))
+	if err != nil {
+		t.Fatalf("decoding body: %v", err)
+	}
+	if !bytes.Equal(decoded, data) {
+		t.Errorf("decoded body does not match the original data")
+	}
+}
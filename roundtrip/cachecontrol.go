@@ -0,0 +1,111 @@
+package roundtrip
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// CacheControlTestHelper verifies that an HTTP cache in front of it honours
+// the Cache-Control directives of the responses it passes on. Install it as
+// the transport the cache under test fetches from; it forwards every request
+// to the wrapped transport and fails the test when the cache
+//
+//   - revalidates a response sent with no-store, which it must not have kept,
+//   - refetches a response still fresh according to its max-age, or
+//   - fetches a stale must-revalidate response unconditionally although it
+//     has a validator (ETag or Last-Modified).
+//
+// Requests sent with Cache-Control: no-cache are exempt from the max-age check.
+type CacheControlTestHelper struct {
+	transport http.RoundTripper
+	now       func() time.Time
+
+	mu   sync.Mutex
+	last map[string]cacheRecord
+
+	t testing.TB
+}
+
+// cacheRecord is the last response the helper passed on for a URL.
+type cacheRecord struct {
+	received       time.Time
+	noStore        bool
+	noCache        bool
+	mustRevalidate bool
+	maxAge         time.Duration
+	hasMaxAge      bool
+	validator      bool
+}
+
+func NewCacheControlTestHelper(t testing.TB, transport http.RoundTripper) *CacheControlTestHelper {
+	return &CacheControlTestHelper{transport: transport, now: time.Now, last: make(map[string]cacheRecord), t: t}
+}
+
+func (h *CacheControlTestHelper) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := req.URL.String()
+	h.mu.Lock()
+	prev, seen := h.last[key]
+	h.mu.Unlock()
+	if seen {
+		h.check(req, prev)
+	}
+
+	resp, err := h.transport.RoundTrip(req)
+	if err != nil || resp.StatusCode == http.StatusNotModified {
+		// a 304 refreshes the stored response, which keeps its directives
+		if err == nil {
+			h.mu.Lock()
+			prev.received = h.now()
+			h.last[key] = prev
+			h.mu.Unlock()
+		}
+		return resp, err
+	}
+
+	h.mu.Lock()
+	h.last[key] = parseCacheRecord(resp, h.now())
+	h.mu.Unlock()
+	return resp, nil
+}
+
+func (h *CacheControlTestHelper) check(req *http.Request, prev cacheRecord) {
+	conditional := req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != ""
+	age := h.now().Sub(prev.received)
+	fresh := prev.hasMaxAge && age < prev.maxAge
+
+	switch {
+	case prev.noStore && conditional:
+		h.t.Errorf("%s %s revalidates a response sent with no-store, so it was stored", req.Method, req.URL)
+	case fresh && !prev.noStore && !prev.noCache && !strings.Contains(req.Header.Get("Cache-Control"), "no-cache"):
+		h.t.Errorf("%s %s refetches a response that is fresh for another %s (max-age)", req.Method, req.URL, prev.maxAge-age)
+	case prev.mustRevalidate && !fresh && prev.validator && !conditional:
+		h.t.Errorf("%s %s fetches a stale must-revalidate response without a conditional request", req.Method, req.URL)
+	}
+}
+
+func parseCacheRecord(resp *http.Response, now time.Time) cacheRecord {
+	rec := cacheRecord{
+		received:  now,
+		validator: resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != "",
+	}
+	for _, directive := range strings.Split(resp.Header.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-store":
+			rec.noStore = true
+		case "no-cache":
+			rec.noCache = true
+		case "must-revalidate":
+			rec.mustRevalidate = true
+		case "max-age":
+			if seconds, err := strconv.Atoi(value); err == nil {
+				rec.maxAge, rec.hasMaxAge = time.Duration(seconds)*time.Second, true
+			}
+		}
+	}
+	return rec
+}
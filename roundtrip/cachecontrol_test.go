@@ -0,0 +1,76 @@
+package roundtrip
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCacheControlTestHelper(t *testing.T) {
+	withCacheControl := func(value string) func(*http.Response) {
+		return func(r *http.Response) { r.Header.Set("Cache-Control", value) }
+	}
+
+	tests := []struct {
+		name string
+		// first is the origin's first response, second its answer to the
+		// follow-up request
+		first, second *http.Response
+		after         time.Duration
+		conditional   bool
+		wantErrors    int
+	}{
+		{"no-store fetched again", NewMockResponse(WithETag("v1"), withCacheControl("no-store")), NewMockResponse(), 0, false, 0},
+		{"no-store revalidated", NewMockResponse(WithETag("v1"), withCacheControl("no-store")), NewMockResponse(), 0, true, 1},
+		{"max-age fresh refetched", NewMockResponse(withCacheControl("max-age=60")), NewMockResponse(), 30 * time.Second, false, 1},
+		{"max-age stale refetched", NewMockResponse(withCacheControl("max-age=60")), NewMockResponse(), 90 * time.Second, false, 0},
+		{"must-revalidate conditional", NewMockResponse(WithETag("v1"), withCacheControl("max-age=60, must-revalidate")), NewMockResponse(WithStatus(http.StatusNotModified)), 90 * time.Second, true, 0},
+		{"must-revalidate unconditional", NewMockResponse(WithETag("v1"), withCacheControl("max-age=60, must-revalidate")), NewMockResponse(), 90 * time.Second, false, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recordingTB{TB: t}
+			origin := &TestingRoundTripper{}
+			origin.WithMockResponses([]*http.Response{tt.first, tt.second})
+			helper := NewCacheControlTestHelper(rec, origin)
+			now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			helper.now = func() time.Time { return now }
+
+			req, _ := http.NewRequest("GET", "https://example.com/doc", nil)
+			if _, err := helper.RoundTrip(req); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			now = now.Add(tt.after)
+			req, _ = http.NewRequest("GET", "https://example.com/doc", nil)
+			if tt.conditional {
+				req.Header.Set("If-None-Match", `"v1"`)
+			}
+			if _, err := helper.RoundTrip(req); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(rec.errors) != tt.wantErrors {
+				t.Errorf("expected %d failures, got %v", tt.wantErrors, rec.errors)
+			}
+		})
+	}
+}
+
+func TestCacheControlTestHelper_ETagCache(t *testing.T) {
+	origin := &TestingRoundTripper{}
+	origin.WithMockResponses([]*http.Response{
+		NewMockResponse(WithETag("v1"), func(r *http.Response) { r.Header.Set("Cache-Control", "no-store") }),
+		NewMockResponse(WithETag("v2")),
+	})
+	// the caching round tripper must not keep the no-store response, so its
+	// second request is unconditional
+	client := &http.Client{Transport: NewETagCachingRoundTripper(NewCacheControlTestHelper(t, origin))}
+	for i := 0; i < 2; i++ {
+		if _, err := client.Get("https://example.com/doc"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if got := origin.Requests()[1].Header.Get("If-None-Match"); got != "" {
+		t.Errorf("expected an unconditional request, got If-None-Match %s", got)
+	}
+}
@@ -0,0 +1,65 @@
+package roundtrip
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// RecordedRequest is a request seen by a RequestCapture.
+type RecordedRequest struct {
+	Method string
+	URL    *url.URL
+	Header http.Header
+	Body   []byte
+	Time   time.Time
+}
+
+// RequestCapture records every request before passing it on to its base
+// transport. Unlike TestingRoundTripper it serves no responses of its own.
+type RequestCapture struct {
+	base http.RoundTripper
+
+	mu       sync.Mutex
+	captured []*RecordedRequest
+}
+
+func NewRequestCapture(base http.RoundTripper) *RequestCapture {
+	return &RequestCapture{base: base}
+}
+
+// Captured returns the requests recorded so far, in the order they were made.
+func (c *RequestCapture) Captured() []*RecordedRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*RecordedRequest(nil), c.captured...)
+}
+
+func (c *RequestCapture) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := &RecordedRequest{
+		Method: req.Method,
+		URL:    new(url.URL),
+		Header: req.Header.Clone(),
+		Time:   time.Now(),
+	}
+	*rec.URL = *req.URL
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("capturing request body: %w", err)
+		}
+		rec.Body = body
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	c.mu.Lock()
+	c.captured = append(c.captured, rec)
+	c.mu.Unlock()
+	return c.base.RoundTrip(req)
+}
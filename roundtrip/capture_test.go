@@ -0,0 +1,52 @@
+package roundtrip
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestCapture(t *testing.T) {
+	trt := &TestingRoundTripper{}
+	trt.WithMockResponses([]*http.Response{NewMockResponse(WithBody([]byte("created"))), NewMockResponse()})
+	capture := NewRequestCapture(trt)
+	client := &http.Client{Transport: capture}
+
+	before := time.Now()
+	req, _ := http.NewRequest("POST", "https://example.com/items", strings.NewReader(`{"name":"gopher"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if body, _ := io.ReadAll(resp.Body); string(body) != "created" {
+		t.Errorf("expected the base response, got '%s'", body)
+	}
+	_, _ = client.Get("https://example.com/items?page=2")
+
+	captured := capture.Captured()
+	if len(captured) != 2 {
+		t.Fatalf("expected 2 captured requests, got %d", len(captured))
+	}
+	first := captured[0]
+	if first.Method != "POST" || first.URL.Path != "/items" || first.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected first request: %s %s %v", first.Method, first.URL, first.Header)
+	}
+	if string(first.Body) != `{"name":"gopher"}` {
+		t.Errorf("expected buffered body, got '%s'", first.Body)
+	}
+	if first.Time.Before(before) || captured[1].Time.Before(first.Time) {
+		t.Errorf("expected increasing timestamps after %v, got %v and %v", before, first.Time, captured[1].Time)
+	}
+	if captured[1].URL.Query().Get("page") != "2" || captured[1].Body != nil {
+		t.Errorf("unexpected second request: %s with body %q", captured[1].URL, captured[1].Body)
+	}
+
+	// the base transport still receives the full body
+	sent, _ := io.ReadAll(trt.Requests()[0].Body)
+	if string(sent) != `{"name":"gopher"}` {
+		t.Errorf("expected body to be passed on, got '%s'", sent)
+	}
+}
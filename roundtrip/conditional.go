@@ -0,0 +1,78 @@
+package roundtrip
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// WithRepeat keeps a response added with AddConditionalResponse registered
+// after it matched, so it answers every matching request. Each request gets a
+// copy with its own body.
+func WithRepeat() func(*http.Response) {
+	return func(r *http.Response) {
+		metaOf(r).repeat = true
+	}
+}
+
+type conditionalResponse struct {
+	queuedResponse
+	// body is the buffered body of a repeated response
+	body []byte
+}
+
+// AddConditionalResponse registers resp for the first request matching
+// matcher. Conditional responses are tried in registration order before the
+// queue, and a match is removed unless resp was created with WithRepeat.
+// Expired responses, see WithExpiry, are removed without matching. Requests
+// matching none of them get the next queued response.
+func (srt *TestingRoundTripper) AddConditionalResponse(matcher RequestMatcher, resp *http.Response) *TestingRoundTripper {
+	c := conditionalResponse{queuedResponse: queuedResponse{resp: resp, responseMeta: takeMeta(resp), matcher: matcher}}
+	if c.repeat {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil && srt.t != nil {
+			srt.t.Errorf("reading body of repeated response: %v", err)
+		}
+		c.body = body
+	}
+	srt.mu.Lock()
+	defer srt.mu.Unlock()
+	srt.conditionals = append(srt.conditionals, c)
+	return srt
+}
+
+// WithMockResponseMap answers the first request to each URL in m, compared
+// as a whole with MatchURL, with its response. Requests to other URLs, or to
+// a URL whose response has been used, get the next queued response.
+func (srt *TestingRoundTripper) WithMockResponseMap(m map[string]*http.Response) *TestingRoundTripper {
+	for url, resp := range m {
+		srt.AddConditionalResponse(MatchURL(url), resp)
+	}
+	return srt
+}
+
+// conditional returns the first conditional response matching req that has
+// not expired, and whether there was one. srt.mu must be held.
+func (srt *TestingRoundTripper) conditional(req *http.Request) (queuedResponse, bool) {
+	for i := 0; i < len(srt.conditionals); i++ {
+		c := srt.conditionals[i]
+		if c.expired(srt.clock()) {
+			srt.conditionals = append(srt.conditionals[:i], srt.conditionals[i+1:]...)
+			i--
+			continue
+		}
+		if !c.matcher(req) {
+			continue
+		}
+		if !c.repeat {
+			srt.conditionals = append(srt.conditionals[:i], srt.conditionals[i+1:]...)
+			return c.queuedResponse, true
+		}
+		resp := *c.resp
+		resp.Header = c.resp.Header.Clone()
+		resp.Body = io.NopCloser(bytes.NewReader(c.body))
+		c.resp = &resp
+		return c.queuedResponse, true
+	}
+	return queuedResponse{}, false
+}
@@ -0,0 +1,70 @@
+package roundtrip
+
+import (
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestTestingRoundTripper_AddConditionalResponse(t *testing.T) {
+	trt := &TestingRoundTripper{}
+	trt.AddMockResponse(NewMockResponse(WithBody([]byte("queued"))))
+	trt.AddConditionalResponse(MatchPath("/token"), NewMockResponse(WithBody([]byte("token"))))
+	trt.AddConditionalResponse(MatchPath("/health"), NewMockResponse(WithBody([]byte("ok")), WithRepeat()))
+	client := &http.Client{Transport: trt}
+
+	for _, tt := range []struct{ path, want string }{
+		{"/health", "ok"},
+		{"/token", "token"},
+		// the token response was used up, the queue answers instead
+		{"/token", "queued"},
+		// repeated responses have a fresh body every time
+		{"/health", "ok"},
+		{"/health", "ok"},
+	} {
+		resp, err := client.Get("https://example.com" + tt.path)
+		if err != nil {
+			t.Fatalf("GET %s: unexpected error: %v", tt.path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != tt.want {
+			t.Errorf("GET %s: expected %q, got %q", tt.path, tt.want, body)
+		}
+	}
+	if trt.Remaining() != 0 {
+		t.Errorf("expected the queue to be drained, %d left", trt.Remaining())
+	}
+}
+
+func TestTestingRoundTripper_WithMockResponseMap(t *testing.T) {
+	trt := (&TestingRoundTripper{}).WithMockResponseMap(map[string]*http.Response{
+		"https://example.com/users/1":      NewMockResponse(WithBody([]byte("alice"))),
+		"https://example.com/users/2":      NewMockResponse(WithBody([]byte("bob"))),
+		"https://example.com/users?page=2": NewMockResponse(WithBody([]byte("page 2"))),
+	})
+	trt.AddMockResponse(NewMockResponse(WithStatus(http.StatusNotFound)))
+	client := &http.Client{Transport: trt}
+
+	for _, tt := range []struct {
+		url    string
+		status int
+		body   string
+	}{
+		{"https://example.com/users/2", http.StatusOK, "bob"},
+		{"https://example.com/users?page=2", http.StatusOK, "page 2"},
+		{"https://example.com/users/1", http.StatusOK, "alice"},
+		// not in the map, falls through to the queue
+		{"https://example.com/users/3", http.StatusNotFound, ""},
+	} {
+		resp, err := client.Get(tt.url)
+		if err != nil {
+			t.Fatalf("GET %s: unexpected error: %v", tt.url, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tt.status || string(body) != tt.body {
+			t.Errorf("GET %s: expected %d %q, got %d %q", tt.url, tt.status, tt.body, resp.StatusCode, body)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package roundtrip
+
+import (
+	"net/http"
+	"time"
+)
+
+type delayedRoundTripper struct {
+	base    http.RoundTripper
+	delayFn func(*http.Request) time.Duration
+}
+
+// NewDelayedRoundTripper wraps base and delays every response by delay after
+// base has returned, simulating a slow transfer rather than a slow server.
+func NewDelayedRoundTripper(base http.RoundTripper, delay time.Duration) http.RoundTripper {
+	return NewVariableDelayRoundTripper(base, func(*http.Request) time.Duration {
+		return delay
+	})
+}
+
+// NewVariableDelayRoundTripper is like NewDelayedRoundTripper but computes the
+// delay for each request with delayFn.
+func NewVariableDelayRoundTripper(base http.RoundTripper, delayFn func(*http.Request) time.Duration) http.RoundTripper {
+	return &delayedRoundTripper{base: base, delayFn: delayFn}
+}
+
+func (d *delayedRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := d.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	timer := time.NewTimer(d.delayFn(req))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return resp, nil
+	case <-req.Context().Done():
+		if resp.Body != nil {
+			_ = resp.Body.Close()
+		}
+		return nil, req.Context().Err()
+	}
+}
@@ -0,0 +1,74 @@
+package roundtrip
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNewDelayedRoundTripper(t *testing.T) {
+	trt := &TestingRoundTripper{}
+	trt.AddMockResponse(NewMockResponse())
+	client := &http.Client{Transport: NewDelayedRoundTripper(trt, 20*time.Millisecond)}
+
+	start := time.Now()
+	resp, err := client.Get("https://example.com/slow")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		t.Errorf("expected status 200, got %d", resp.StatusCode)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("expected at least 20ms delay, got %v", elapsed)
+	}
+}
+
+func TestNewDelayedRoundTripper_ContextCanceled(t *testing.T) {
+	trt := &TestingRoundTripper{}
+	trt.AddMockResponse(NewMockResponse())
+	client := &http.Client{Transport: NewDelayedRoundTripper(trt, time.Hour)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", "https://example.com/slow", nil)
+	_, err := client.Do(req)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestNewVariableDelayRoundTripper(t *testing.T) {
+	trt := &TestingRoundTripper{}
+	trt.WithMockResponses([]*http.Response{NewMockResponse(), NewMockResponse()})
+
+	var seen []string
+	client := &http.Client{Transport: NewVariableDelayRoundTripper(trt, func(req *http.Request) time.Duration {
+		seen = append(seen, req.URL.Path)
+		if req.URL.Path == "/slow" {
+			return 20 * time.Millisecond
+		}
+		return 0
+	})}
+
+	start := time.Now()
+	if _, err := client.Get("https://example.com/fast"); err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= 20*time.Millisecond {
+		t.Errorf("expected fast request to have no delay, took %v", elapsed)
+	}
+
+	start = time.Now()
+	if _, err := client.Get("https://example.com/slow"); err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("expected slow request to be delayed, took %v", elapsed)
+	}
+	if len(seen) != 2 {
+		t.Errorf("expected delay to be computed per request, got %v", seen)
+	}
+}
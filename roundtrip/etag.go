@@ -0,0 +1,105 @@
+package roundtrip
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// WithETag sets the ETag header to the quoted tag.
+func WithETag(tag string) func(*http.Response) {
+	return func(r *http.Response) {
+		if r.Header == nil {
+			r.Header = make(http.Header)
+		}
+		r.Header.Set("ETag", strconv.Quote(tag))
+	}
+}
+
+type cachedResponse struct {
+	etag   string
+	resp   *http.Response
+	body   []byte
+	header http.Header
+}
+
+type etagCachingRoundTripper struct {
+	base http.RoundTripper
+
+	mu    sync.Mutex
+	cache map[string]cachedResponse
+}
+
+// NewETagCachingRoundTripper wraps base with a cache for GET responses
+// carrying an ETag. A cached URL is revalidated with If-None-Match; on 304 Not
+// Modified the cached response is returned with a fresh copy of its body.
+// Another 200 replaces the cache entry, or removes it if it has no ETag or is
+// sent with Cache-Control: no-store, and a successful request with any other
+// method than GET or HEAD invalidates it.
+func NewETagCachingRoundTripper(base http.RoundTripper) http.RoundTripper {
+	return &etagCachingRoundTripper{base: base, cache: make(map[string]cachedResponse)}
+}
+
+func (c *etagCachingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := req.URL.String()
+	if req.Method != http.MethodGet {
+		resp, err := c.base.RoundTrip(req)
+		if err == nil && req.Method != http.MethodHead && resp.StatusCode < 400 {
+			c.mu.Lock()
+			delete(c.cache, key)
+			c.mu.Unlock()
+		}
+		return resp, err
+	}
+
+	c.mu.Lock()
+	cached, ok := c.cache[key]
+	c.mu.Unlock()
+	if ok && req.Header.Get("If-None-Match") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+
+	resp, err := c.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotModified && ok:
+		_ = resp.Body.Close()
+		return cached.response(req), nil
+	case resp.StatusCode == http.StatusOK:
+		etag := resp.Header.Get("ETag")
+		if etag == "" || strings.Contains(resp.Header.Get("Cache-Control"), "no-store") {
+			c.mu.Lock()
+			delete(c.cache, key)
+			c.mu.Unlock()
+			return resp, nil
+		}
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		entry := cachedResponse{etag: etag, resp: resp, body: body, header: resp.Header.Clone()}
+		c.mu.Lock()
+		c.cache[key] = entry
+		c.mu.Unlock()
+		return entry.response(req), nil
+	}
+	return resp, nil
+}
+
+// response returns a copy of the cached response with its own body and
+// headers.
+func (e cachedResponse) response(req *http.Request) *http.Response {
+	resp := *e.resp
+	resp.Header = e.header.Clone()
+	resp.Body = io.NopCloser(bytes.NewReader(e.body))
+	resp.ContentLength = int64(len(e.body))
+	resp.Request = req
+	return &resp
+}
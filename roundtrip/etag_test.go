@@ -0,0 +1,64 @@
+package roundtrip
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestWithETag(t *testing.T) {
+	resp := NewMockResponse(WithETag("v1"))
+	if got := resp.Header.Get("ETag"); got != `"v1"` {
+		t.Errorf(`expected ETag '"v1"', got '%s'`, got)
+	}
+}
+
+func TestETagCachingRoundTripper(t *testing.T) {
+	trt := &TestingRoundTripper{}
+	trt.WithMockResponses([]*http.Response{
+		NewMockResponse(WithETag("v1"), WithBody([]byte("first"))),
+		NewMockResponse(WithStatus(http.StatusNotModified)),
+		NewMockResponse(WithETag("v2"), WithBody([]byte("second"))),
+		NewMockResponse(WithStatus(http.StatusNoContent)),
+		NewMockResponse(WithETag("v3"), WithBody([]byte("third"))),
+	})
+	client := &http.Client{Transport: NewETagCachingRoundTripper(trt)}
+
+	get := func() string {
+		t.Helper()
+		resp, err := client.Get("https://example.com/doc")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	// miss, then a hit served from the cache, then a changed document
+	for i, want := range []string{"first", "first", "second"} {
+		if got := get(); got != want {
+			t.Errorf("request %d: expected body '%s', got '%s'", i, want, got)
+		}
+	}
+	// a write invalidates the cache, so the next GET is unconditional
+	req, _ := http.NewRequest("PUT", "https://example.com/doc", strings.NewReader("new"))
+	if _, err := client.Do(req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := get(); got != "third" {
+		t.Errorf("expected body 'third', got '%s'", got)
+	}
+
+	var conditions []string
+	for _, r := range trt.Requests() {
+		conditions = append(conditions, r.Header.Get("If-None-Match"))
+	}
+	want := []string{"", `"v1"`, `"v1"`, "", ""}
+	if strings.Join(conditions, ",") != strings.Join(want, ",") {
+		t.Errorf("expected If-None-Match %q, got %q", want, conditions)
+	}
+}
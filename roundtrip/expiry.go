@@ -0,0 +1,16 @@
+package roundtrip
+
+import (
+	"net/http"
+	"time"
+)
+
+// WithExpiry makes the response valid only until deadline. A
+// TestingRoundTripper that reaches the response after deadline skips it as if
+// it had never been added, whether it was queued, added with
+// AddConditionalResponse or returned by a response mapper.
+func WithExpiry(deadline time.Time) func(*http.Response) {
+	return func(r *http.Response) {
+		metaOf(r).expiry = deadline
+	}
+}
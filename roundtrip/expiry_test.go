@@ -0,0 +1,122 @@
+package roundtrip
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestWithExpiry(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+	clock := func() time.Time { return now }
+
+	t.Run("valid before the deadline", func(t *testing.T) {
+		trt := &TestingRoundTripper{}
+		trt.WithClock(clock).AddMockResponse(NewMockResponse(WithBody([]byte("token")), WithExpiry(start.Add(time.Minute))))
+		now = start
+
+		resp, err := (&http.Client{Transport: trt}).Get("https://example.com/token")
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		if string(b) != "token" {
+			t.Errorf("expected body 'token', got '%s'", string(b))
+		}
+	})
+
+	t.Run("skipped after the deadline", func(t *testing.T) {
+		trt := &TestingRoundTripper{}
+		trt.WithClock(clock).WithMockResponses([]*http.Response{
+			NewMockResponse(WithBody([]byte("cached")), WithExpiry(start.Add(time.Minute))),
+			NewMockResponse(WithBody([]byte("fresh"))),
+		})
+		now = start.Add(2 * time.Minute)
+
+		resp, err := (&http.Client{Transport: trt}).Get("https://example.com/token")
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		if string(b) != "fresh" {
+			t.Errorf("expected the expired response to be skipped, got '%s'", string(b))
+		}
+	})
+
+	t.Run("expired conditional falls through", func(t *testing.T) {
+		trt := &TestingRoundTripper{}
+		trt.WithClock(clock).AddMockResponse(NewMockResponse(WithBody([]byte("queued"))))
+		trt.AddConditionalResponse(MatchPath("/token"), NewMockResponse(WithBody([]byte("cached")), WithExpiry(start.Add(time.Minute))))
+		trt.AddConditionalResponse(MatchPath("/token"), NewMockResponse(WithBody([]byte("fresh"))))
+		now = start.Add(2 * time.Minute)
+
+		resp, err := (&http.Client{Transport: trt}).Get("https://example.com/token")
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		if string(b) != "fresh" {
+			t.Errorf("expected the next matching response, got '%s'", string(b))
+		}
+	})
+
+	t.Run("expired mapped response falls through", func(t *testing.T) {
+		trt := &TestingRoundTripper{}
+		trt.WithClock(clock).AddMockResponse(NewMockResponse(WithBody([]byte("queued"))))
+		trt.WithResponseMapper(func(*http.Request) (*http.Response, bool) {
+			return NewMockResponse(WithBody([]byte("mapped")), WithExpiry(start.Add(time.Minute))), true
+		})
+		now = start.Add(2 * time.Minute)
+
+		resp, err := (&http.Client{Transport: trt}).Get("https://example.com/token")
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		if string(b) != "queued" {
+			t.Errorf("expected the queued response, got '%s'", string(b))
+		}
+	})
+
+	t.Run("mapped response returned again expires", func(t *testing.T) {
+		trt := &TestingRoundTripper{}
+		trt.WithClock(clock).AddMockResponse(NewMockResponse(WithBody([]byte("queued"))))
+		mapped := NewMockResponse(WithExpiry(start.Add(time.Minute)), WithBody([]byte("mapped")))
+		trt.WithResponseMapper(func(*http.Request) (*http.Response, bool) {
+			return mapped, true
+		})
+		client := &http.Client{Transport: trt}
+
+		now = start
+		resp, err := client.Get("https://example.com/token")
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		if b, _ := io.ReadAll(resp.Body); string(b) != "mapped" {
+			t.Errorf("expected the mapped response, got '%s'", string(b))
+		}
+
+		now = start.Add(2 * time.Minute)
+		resp, err = client.Get("https://example.com/token")
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		if b, _ := io.ReadAll(resp.Body); string(b) != "queued" {
+			t.Errorf("expected the queued response, got '%s'", string(b))
+		}
+	})
+
+	t.Run("no response left once expired", func(t *testing.T) {
+		trt := &TestingRoundTripper{}
+		trt.WithClock(clock).AddMockResponse(NewMockResponse(WithExpiry(start.Add(time.Minute))))
+		now = start.Add(2 * time.Minute)
+
+		_, err := (&http.Client{Transport: trt}).Get("https://example.com/token")
+		if !errors.Is(err, ErrNoMockResponse) {
+			t.Errorf("expected ErrNoMockResponse, got %v", err)
+		}
+	})
+}
@@ -1,3 +1,10 @@
 module github.com/peeperklip/stuff/roundtrip
 
 go 1.24
+
+require (
+	github.com/andybalholm/brotli v1.2.5
+	go.opentelemetry.io/otel/trace v1.38.0
+)
+
+require go.opentelemetry.io/otel v1.38.0 // indirect
@@ -0,0 +1,88 @@
+package roundtrip
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// handlerRoundTripper serves requests from an http.Handler in process.
+type handlerRoundTripper struct {
+	handler http.Handler
+}
+
+func (h handlerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	h.handler.ServeHTTP(rec, req)
+	return rec.Result(), nil
+}
+
+func TestHMACSigning(t *testing.T) {
+	secret := []byte("s3cret")
+	var received []byte
+	handler := NewHMACValidatingHandler(secret, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = io.ReadAll(r.Body)
+	}))
+
+	t.Run("signed request is accepted", func(t *testing.T) {
+		client := &http.Client{Transport: NewHMACVerifyingRoundTripper(handlerRoundTripper{handler}, secret, HMACSignatureHeader)}
+		resp, err := client.Post("https://example.com/hook", "text/plain", bytes.NewReader([]byte("payload")))
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		if resp.StatusCode != 200 {
+			t.Fatalf("expected status 200, got %d", resp.StatusCode)
+		}
+		if string(received) != "payload" {
+			t.Errorf("expected handler to receive 'payload', got '%s'", string(received))
+		}
+	})
+
+	t.Run("wrong secret is rejected", func(t *testing.T) {
+		client := &http.Client{Transport: NewHMACVerifyingRoundTripper(handlerRoundTripper{handler}, []byte("wrong"), HMACSignatureHeader)}
+		resp, err := client.Post("https://example.com/hook", "text/plain", bytes.NewReader([]byte("payload")))
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		if resp.StatusCode != 401 {
+			t.Errorf("expected status 401, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("unsigned request is rejected", func(t *testing.T) {
+		client := &http.Client{Transport: handlerRoundTripper{handler}}
+		resp, err := client.Post("https://example.com/hook", "text/plain", bytes.NewReader([]byte("payload")))
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		if resp.StatusCode != 401 {
+			t.Errorf("expected status 401, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("custom header", func(t *testing.T) {
+		custom := NewHMACHeaderValidatingHandler(secret, "X-Hub-Signature", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		client := &http.Client{Transport: NewHMACVerifyingRoundTripper(handlerRoundTripper{custom}, secret, "X-Hub-Signature")}
+		resp, err := client.Post("https://example.com/hook", "text/plain", bytes.NewReader([]byte("payload")))
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		if resp.StatusCode != 200 {
+			t.Errorf("expected status 200, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("signature header is set", func(t *testing.T) {
+		trt := &TestingRoundTripper{}
+		trt.AddMockResponse(NewMockResponse())
+		client := &http.Client{Transport: NewHMACVerifyingRoundTripper(trt, secret, HMACSignatureHeader)}
+		_, _ = client.Get("https://example.com/empty")
+
+		want := signBody(secret, nil)
+		if got := trt.Requests()[0].Header.Get(HMACSignatureHeader); got != want {
+			t.Errorf("expected signature '%s', got '%s'", want, got)
+		}
+	})
+}
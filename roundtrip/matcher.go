@@ -0,0 +1,79 @@
+package roundtrip
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// RequestMatcher reports whether a request is the one a mock expects.
+type RequestMatcher func(*http.Request) bool
+
+// MatchMethod matches requests with the given HTTP method.
+func MatchMethod(method string) RequestMatcher {
+	return func(req *http.Request) bool {
+		return req.Method == method
+	}
+}
+
+// MatchPath matches requests whose URL path equals path.
+func MatchPath(path string) RequestMatcher {
+	return func(req *http.Request) bool {
+		return req.URL.Path == path
+	}
+}
+
+// MatchURL matches requests whose full URL, as returned by URL.String,
+// equals url.
+func MatchURL(url string) RequestMatcher {
+	return func(req *http.Request) bool {
+		return req.URL.String() == url
+	}
+}
+
+// MatchAll matches requests that satisfy every one of matchers.
+func MatchAll(matchers ...RequestMatcher) RequestMatcher {
+	return func(req *http.Request) bool {
+		for _, m := range matchers {
+			if !m(req) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// WithBasicAuthRequired matches requests carrying basic auth credentials
+// username and password. Pass it to TestingRoundTripper.WithAuthRequired to
+// answer all other requests with 401 Unauthorized.
+func WithBasicAuthRequired(username, password string) RequestMatcher {
+	return func(req *http.Request) bool {
+		user, pass, ok := req.BasicAuth()
+		return ok && subtle.ConstantTimeCompare([]byte(user), []byte(username)) == 1 &&
+			subtle.ConstantTimeCompare([]byte(pass), []byte(password)) == 1
+	}
+}
+
+// WithBearerToken matches requests whose Authorization header is exactly
+// "Bearer <token>". Requests without the header, with another scheme or with
+// extra whitespace do not match, and neither does any request for an empty
+// token.
+func WithBearerToken(token string) RequestMatcher {
+	return func(req *http.Request) bool {
+		return token != "" && req.Header.Get("Authorization") == "Bearer "+token
+	}
+}
+
+// WithIdempotencyKey matches requests carrying a non-empty Idempotency-Key
+// header.
+func WithIdempotencyKey() RequestMatcher {
+	return func(req *http.Request) bool {
+		return req.Header.Get("Idempotency-Key") != ""
+	}
+}
+
+// RequestExpectation pairs a matcher with the response served to the request
+// it matches, see ExpectInOrder.
+type RequestExpectation struct {
+	Matcher  RequestMatcher
+	Response *http.Response
+}
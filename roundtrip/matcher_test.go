@@ -0,0 +1,116 @@
+package roundtrip
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestMatchers(t *testing.T) {
+	req, _ := http.NewRequest("POST", "https://example.com/refresh?x=1", nil)
+
+	tests := []struct {
+		name    string
+		matcher RequestMatcher
+		want    bool
+	}{
+		{"method matches", MatchMethod("POST"), true},
+		{"method differs", MatchMethod("GET"), false},
+		{"path matches", MatchPath("/refresh"), true},
+		{"path differs", MatchPath("/token"), false},
+		{"URL matches", MatchURL("https://example.com/refresh?x=1"), true},
+		{"URL differs in query", MatchURL("https://example.com/refresh"), false},
+		{"all match", MatchAll(MatchMethod("POST"), MatchPath("/refresh")), true},
+		{"one differs", MatchAll(MatchMethod("POST"), MatchPath("/token")), false},
+		{"empty matches everything", MatchAll(), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.matcher(req); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestWithBasicAuthRequired(t *testing.T) {
+	trt := &TestingRoundTripper{}
+	trt.AddMockResponse(NewMockResponse(WithBody([]byte("welcome"))))
+	trt.WithAuthRequired(WithBasicAuthRequired("gopher", "secret"))
+	client := &http.Client{Transport: trt}
+
+	tests := []struct {
+		name       string
+		user, pass string
+		wantStatus int
+	}{
+		{"missing", "", "", http.StatusUnauthorized},
+		{"wrong password", "gopher", "guess", http.StatusUnauthorized},
+		{"wrong user", "admin", "secret", http.StatusUnauthorized},
+		{"valid", "gopher", "secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "https://example.com/private", nil)
+			if tt.user != "" {
+				req.SetBasicAuth(tt.user, tt.pass)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+			if tt.wantStatus == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") == "" {
+				t.Errorf("expected a WWW-Authenticate challenge")
+			}
+		})
+	}
+	if trt.Remaining() != 0 {
+		t.Errorf("expected only the valid request to consume the queue, %d remaining", trt.Remaining())
+	}
+}
+
+func TestWithBearerToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   bool
+	}{
+		{"valid", "Bearer abc123", true},
+		{"missing", "", false},
+		{"other token", "Bearer xyz", false},
+		{"missing token", "Bearer", false},
+		{"lower case scheme", "bearer abc123", false},
+		{"extra space", "Bearer  abc123", false},
+		{"basic scheme", "Basic abc123", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "https://example.com/me", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			if got := WithBearerToken("abc123")(req); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	req, _ := http.NewRequest("GET", "https://example.com/me", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	if WithBearerToken("")(req) {
+		t.Errorf("expected an empty token never to match")
+	}
+}
+
+func TestWithIdempotencyKey(t *testing.T) {
+	req, _ := http.NewRequest("POST", "https://example.com/payments", nil)
+	if WithIdempotencyKey()(req) {
+		t.Errorf("expected request without key not to match")
+	}
+	req.Header.Set("Idempotency-Key", "key-1")
+	if !WithIdempotencyKey()(req) {
+		t.Errorf("expected request with key to match")
+	}
+}
@@ -0,0 +1,66 @@
+package roundtrip
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+// responseMeta is what the options of this package record about a mock
+// response beyond its fields, such as WithExpiry. A TestingRoundTripper keeps
+// it with the response when the response is added.
+type responseMeta struct {
+	expiry  time.Time
+	timeout time.Duration
+	repeat  bool
+}
+
+// metaBody carries the responseMeta of a response on its body, the one field
+// of http.Response an option can wrap, so the metadata lives and dies with the
+// response. The options of this package that set the body keep the wrapper.
+type metaBody struct {
+	io.ReadCloser
+	meta responseMeta
+}
+
+// metaOf returns the metadata of r for an option to fill in, wrapping r.Body
+// on first use.
+func metaOf(r *http.Response) *responseMeta {
+	mb, ok := r.Body.(*metaBody)
+	if !ok {
+		body := r.Body
+		if body == nil {
+			body = http.NoBody
+		}
+		mb = &metaBody{ReadCloser: body}
+		r.Body = mb
+	}
+	return &mb.meta
+}
+
+// setBody replaces the body of r, keeping its metadata.
+func setBody(r *http.Response, body io.ReadCloser) {
+	if mb, ok := r.Body.(*metaBody); ok {
+		mb.ReadCloser = body
+		return
+	}
+	r.Body = body
+}
+
+// takeMeta unwraps the body of r and returns its metadata.
+func takeMeta(r *http.Response) responseMeta {
+	if r == nil {
+		return responseMeta{}
+	}
+	mb, ok := r.Body.(*metaBody)
+	if !ok {
+		return responseMeta{}
+	}
+	r.Body = mb.ReadCloser
+	return mb.meta
+}
+
+// expired reports whether the deadline set by WithExpiry has passed at now.
+func (m responseMeta) expired(now time.Time) bool {
+	return !m.expiry.IsZero() && now.After(m.expiry)
+}
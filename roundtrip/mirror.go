@@ -0,0 +1,133 @@
+package roundtrip
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// mirrorBodyLimit is the number of body bytes written by the mirroring round
+// tripper; longer bodies are truncated.
+const mirrorBodyLimit = 1024
+
+type mirroringRoundTripper struct {
+	base http.RoundTripper
+
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// NewMirroringRoundTripper wraps base and writes every request and response
+// to out in an HTTP/1.1-like wire format, like curl -v: request lines are
+// prefixed with "> ", response lines with "< ". Headers are sorted and bodies
+// truncated after 1 KiB. Bodies are still passed on in full, and only the
+// truncated part is buffered.
+func NewMirroringRoundTripper(base http.RoundTripper, out io.Writer) http.RoundTripper {
+	return &mirroringRoundTripper{base: base, out: out}
+}
+
+func (m *mirroringRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s %s %s\n", req.Method, req.URL.RequestURI(), protoOrDefault(req.Proto))
+	writeMirrorHeader(&buf, req.Host, req.URL.Host, req.Header)
+	if req.Body != nil && req.Body != http.NoBody {
+		req = req.Clone(req.Context())
+		req.Body = peekBody(&buf, req.Body)
+	}
+	m.write("> ", &buf)
+
+	resp, err := m.base.RoundTrip(req)
+	if err != nil {
+		m.write("< ", bytes.NewBufferString(fmt.Sprintf("error: %v\n", err)))
+		return resp, err
+	}
+
+	buf.Reset()
+	fmt.Fprintf(&buf, "%s %s\n", protoOrDefault(resp.Proto), resp.Status)
+	writeMirrorHeader(&buf, "", "", resp.Header)
+	if resp.Body != nil {
+		resp.Body = peekBody(&buf, resp.Body)
+	}
+	m.write("< ", &buf)
+	return resp, nil
+}
+
+// write prefixes every line of buf and writes it to out in one piece, so
+// concurrent round trips do not interleave.
+func (m *mirroringRoundTripper) write(prefix string, buf *bytes.Buffer) {
+	var out strings.Builder
+	sc := bufio.NewScanner(buf)
+	for sc.Scan() {
+		if sc.Text() == "" {
+			// no trailing space on the blank line ending the header
+			out.WriteString(strings.TrimSpace(prefix))
+		} else {
+			out.WriteString(prefix + sc.Text())
+		}
+		out.WriteByte('\n')
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, _ = io.WriteString(m.out, out.String())
+}
+
+func protoOrDefault(proto string) string {
+	if proto == "" {
+		return "HTTP/1.1"
+	}
+	return proto
+}
+
+func writeMirrorHeader(buf *bytes.Buffer, host, urlHost string, header http.Header) {
+	if host == "" {
+		host = urlHost
+	}
+	if host != "" {
+		fmt.Fprintf(buf, "Host: %s\n", host)
+	}
+	for _, k := range slices.Sorted(maps.Keys(header)) {
+		for _, v := range header[k] {
+			fmt.Fprintf(buf, "%s: %s\n", k, v)
+		}
+	}
+	buf.WriteString("\n")
+}
+
+// peekBody writes up to mirrorBodyLimit bytes of body to buf and returns a
+// body yielding the full content.
+func peekBody(buf *bytes.Buffer, body io.ReadCloser) io.ReadCloser {
+	head := make([]byte, mirrorBodyLimit+1)
+	n, err := io.ReadFull(body, head)
+	head = head[:n]
+	if n > mirrorBodyLimit {
+		buf.Write(head[:mirrorBodyLimit])
+		buf.WriteString("\n... (truncated)\n")
+	} else if n > 0 {
+		buf.Write(head)
+		buf.WriteString("\n")
+	}
+
+	var rest io.Reader = body
+	if err != nil {
+		// body is exhausted or failed; report a read error to the caller
+		// once the buffered part has been consumed
+		rest = errReader{err}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			rest = bytes.NewReader(nil)
+		}
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), rest), body}
+}
+
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
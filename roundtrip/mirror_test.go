@@ -0,0 +1,71 @@
+package roundtrip
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMirroringRoundTripper(t *testing.T) {
+	trt := &TestingRoundTripper{}
+	trt.AddMockResponse(NewMockResponse(WithStatus(http.StatusCreated), WithBody([]byte(`{"id":1}`)),
+		func(r *http.Response) { r.Header.Set("Content-Type", "application/json") }))
+
+	var out bytes.Buffer
+	client := &http.Client{Transport: NewMirroringRoundTripper(trt, &out)}
+	req, _ := http.NewRequest("POST", "https://api.example.com/users?dry=1", strings.NewReader(`{"name":"gopher"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := `> POST /users?dry=1 HTTP/1.1
+> Host: api.example.com
+> Accept: application/json
+> Content-Type: application/json
+>
+> {"name":"gopher"}
+< HTTP/1.1 201 Created
+< Content-Type: application/json
+<
+< {"id":1}
+`
+	if out.String() != want {
+		t.Errorf("expected output\n%s\ngot\n%s", want, out.String())
+	}
+
+	// bodies are still delivered in full
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != `{"id":1}` {
+		t.Errorf("expected response body to be preserved, got '%s'", body)
+	}
+	sent, _ := io.ReadAll(trt.Requests()[0].Body)
+	if string(sent) != `{"name":"gopher"}` {
+		t.Errorf("expected request body to be preserved, got '%s'", sent)
+	}
+}
+
+func TestMirroringRoundTripper_TruncatesBody(t *testing.T) {
+	large := bytes.Repeat([]byte("a"), 4096)
+	trt := &TestingRoundTripper{}
+	trt.AddMockResponse(NewMockResponse(WithBody(large)))
+
+	var out bytes.Buffer
+	client := &http.Client{Transport: NewMirroringRoundTripper(trt, &out)}
+	resp, err := client.Get("https://example.com/large")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "< ... (truncated)\n") || strings.Contains(out.String(), strings.Repeat("a", 1025)) {
+		t.Errorf("expected truncated body in output, got %d bytes", out.Len())
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if !bytes.Equal(body, large) {
+		t.Errorf("expected full body of %d bytes, got %d", len(large), len(body))
+	}
+}
@@ -0,0 +1,122 @@
+package roundtrip
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"testing"
+)
+
+// multipartPart is one part of a logged multipart/form-data body.
+type multipartPart struct {
+	filename    string
+	contentType string
+	content     string
+}
+
+// AssertNthRequestIsMultipartForm fails the test unless the n-th logged
+// request, counting from 0, has a multipart/form-data body containing every
+// field of expectedFields with the given value. For file parts the value is
+// compared with the file content. The request body must still be readable,
+// e.g. through WithRequestBuffering.
+func (srt *TestingRoundTripper) AssertNthRequestIsMultipartForm(t testing.TB, n int, expectedFields map[string]string) {
+	t.Helper()
+	parts, ok := srt.multipartParts(t, n)
+	if !ok {
+		return
+	}
+	for field, want := range expectedFields {
+		part, ok := parts[field]
+		switch {
+		case !ok:
+			t.Errorf("request %d has no multipart field %q", n, field)
+		case part.content != want:
+			t.Errorf("request %d: expected multipart field %q to be %q, got %q", n, field, want, part.content)
+		}
+	}
+}
+
+// AssertNthRequestMultipartFile fails the test unless the n-th logged request
+// has a file part named field. filename and contentType are only compared
+// when not empty.
+func (srt *TestingRoundTripper) AssertNthRequestMultipartFile(t testing.TB, n int, field, filename, contentType string) {
+	t.Helper()
+	parts, ok := srt.multipartParts(t, n)
+	if !ok {
+		return
+	}
+	part, ok := parts[field]
+	switch {
+	case !ok || part.filename == "":
+		t.Errorf("request %d has no multipart file %q", n, field)
+	case filename != "" && part.filename != filename:
+		t.Errorf("request %d: expected file %q to be named %q, got %q", n, field, filename, part.filename)
+	case contentType != "" && part.contentType != contentType:
+		t.Errorf("request %d: expected file %q to have Content-Type %q, got %q", n, field, contentType, part.contentType)
+	}
+}
+
+// multipartParts parses the body of the n-th logged request by field name.
+func (srt *TestingRoundTripper) multipartParts(t testing.TB, n int) (map[string]multipartPart, bool) {
+	t.Helper()
+	req, ok := srt.nthRequest(t, n)
+	if !ok {
+		return nil, false
+	}
+	mediaType, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" {
+		t.Errorf("request %d has Content-Type %q, expected multipart/form-data", n, req.Header.Get("Content-Type"))
+		return nil, false
+	}
+	body, err := requestBody(req)
+	if err != nil {
+		t.Errorf("reading body of request %d: %v", n, err)
+		return nil, false
+	}
+
+	parts := make(map[string]multipartPart)
+	mr := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return parts, true
+		}
+		if err != nil {
+			t.Errorf("parsing multipart body of request %d: %v", n, err)
+			return nil, false
+		}
+		content, err := io.ReadAll(p)
+		if err != nil {
+			t.Errorf("reading multipart field %q of request %d: %v", p.FormName(), n, err)
+			return nil, false
+		}
+		parts[p.FormName()] = multipartPart{
+			filename:    p.FileName(),
+			contentType: p.Header.Get("Content-Type"),
+			content:     string(content),
+		}
+	}
+}
+
+// requestBody returns the body of a logged request without consuming it for
+// later assertions.
+func requestBody(req *http.Request) ([]byte, error) {
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+	if req.Body == nil {
+		return nil, fmt.Errorf("%s %s has no body", req.Method, req.URL)
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, err
+}
@@ -0,0 +1,73 @@
+package roundtrip
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"testing"
+)
+
+func TestTestingRoundTripper_AssertNthRequestIsMultipartForm(t *testing.T) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	_ = w.WriteField("title", "holiday")
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", `form-data; name="photo"; filename="beach.png"`)
+	header.Set("Content-Type", "image/png")
+	part, _ := w.CreatePart(header)
+	_, _ = part.Write([]byte("png bytes"))
+	_ = w.Close()
+
+	trt := (&TestingRoundTripper{}).WithRequestBuffering()
+	trt.WithMockResponses([]*http.Response{NewMockResponse(), NewMockResponse()})
+	client := &http.Client{Transport: trt}
+	_, _ = client.Post("https://example.com/upload", w.FormDataContentType(), &body)
+	_, _ = client.Post("https://example.com/json", "application/json", bytes.NewReader([]byte("{}")))
+
+	t.Run("fields", func(t *testing.T) {
+		tests := []struct {
+			name       string
+			n          int
+			fields     map[string]string
+			wantErrors int
+		}{
+			{"matching fields", 0, map[string]string{"title": "holiday", "photo": "png bytes"}, 0},
+			{"wrong value", 0, map[string]string{"title": "work"}, 1},
+			{"missing field", 0, map[string]string{"caption": "sunset"}, 1},
+			{"not multipart", 1, map[string]string{"title": "holiday"}, 1},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				rec := &recordingTB{TB: t}
+				trt.AssertNthRequestIsMultipartForm(rec, tt.n, tt.fields)
+				if len(rec.errors) != tt.wantErrors {
+					t.Errorf("expected %d failures, got %v", tt.wantErrors, rec.errors)
+				}
+			})
+		}
+	})
+
+	t.Run("files", func(t *testing.T) {
+		tests := []struct {
+			name                  string
+			field, file, mimeType string
+			wantErrors            int
+		}{
+			{"field only", "photo", "", "", 0},
+			{"filename and type", "photo", "beach.png", "image/png", 0},
+			{"wrong filename", "photo", "mountain.png", "", 1},
+			{"wrong type", "photo", "", "image/jpeg", 1},
+			{"not a file", "title", "", "", 1},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				rec := &recordingTB{TB: t}
+				trt.AssertNthRequestMultipartFile(rec, 0, tt.field, tt.file, tt.mimeType)
+				if len(rec.errors) != tt.wantErrors {
+					t.Errorf("expected %d failures, got %v", tt.wantErrors, rec.errors)
+				}
+			})
+		}
+	})
+}
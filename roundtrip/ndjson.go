@@ -0,0 +1,51 @@
+package roundtrip
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// dripReader yields one line at a time, waiting delay before each line.
+type dripReader struct {
+	lines [][]byte
+	delay time.Duration
+	cur   []byte
+}
+
+func (d *dripReader) Read(p []byte) (int, error) {
+	if len(d.cur) == 0 {
+		if len(d.lines) == 0 {
+			return 0, io.EOF
+		}
+		time.Sleep(d.delay)
+		d.cur, d.lines = d.lines[0], d.lines[1:]
+	}
+	n := copy(p, d.cur)
+	d.cur = d.cur[n:]
+	return n, nil
+}
+
+// NewNDJSONResponse returns a 200 OK newline delimited JSON stream with one
+// line per object. The body delivers a line every lineDelay, simulating an
+// API that streams results as they become available.
+func NewNDJSONResponse(objects []any, lineDelay time.Duration) (*http.Response, error) {
+	lines := make([][]byte, 0, len(objects))
+	for i, o := range objects {
+		b, err := json.Marshal(o)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling object %d: %w", i, err)
+		}
+		lines = append(lines, append(b, '\n'))
+	}
+
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Status:        fmt.Sprintf("%d %s", http.StatusOK, http.StatusText(http.StatusOK)),
+		Header:        http.Header{"Content-Type": {"application/x-ndjson"}},
+		Body:          io.NopCloser(&dripReader{lines: lines, delay: lineDelay}),
+		ContentLength: -1,
+	}, nil
+}
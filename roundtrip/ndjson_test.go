@@ -0,0 +1,57 @@
+package roundtrip
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNewNDJSONResponse(t *testing.T) {
+	type event struct {
+		ID int `json:"id"`
+	}
+
+	resp, err := NewNDJSONResponse([]any{event{1}, event{2}, event{3}}, 5*time.Millisecond)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	trt := &TestingRoundTripper{}
+	trt.AddMockResponse(resp)
+	client := &http.Client{Transport: trt}
+
+	start := time.Now()
+	res, err := client.Get("https://example.com/stream")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if ct := res.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("expected Content-Type 'application/x-ndjson', got '%s'", ct)
+	}
+
+	dec := json.NewDecoder(res.Body)
+	for want := 1; want <= 3; want++ {
+		var ev event
+		if err := dec.Decode(&ev); err != nil {
+			t.Fatalf("decoding event %d: %v", want, err)
+		}
+		if ev.ID != want {
+			t.Errorf("expected event %d, got %d", want, ev.ID)
+		}
+	}
+	var extra event
+	if err := dec.Decode(&extra); !errors.Is(err, io.EOF) {
+		t.Errorf("expected EOF after the last event, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Errorf("expected lines to drip in over at least 15ms, got %v", elapsed)
+	}
+}
+
+func TestNewNDJSONResponse_MarshalError(t *testing.T) {
+	if _, err := NewNDJSONResponse([]any{make(chan int)}, 0); err == nil {
+		t.Errorf("expected marshaling error")
+	}
+}
@@ -0,0 +1,145 @@
+package roundtrip
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// ContentNegotiationMock serves one registered response per media type and
+// picks it by the request's Accept header. Quality values and wildcards like
+// "application/*" are honoured. Requests without an Accept header get the
+// first registered response; requests accepting none of the registered types
+// get 406 Not Acceptable.
+type ContentNegotiationMock struct {
+	mu       sync.Mutex
+	types    []string
+	handlers map[string]negotiated
+
+	t testing.TB
+}
+
+type negotiated struct {
+	resp *http.Response
+	body []byte
+}
+
+func NewContentNegotiationMock(t testing.TB) *ContentNegotiationMock {
+	return &ContentNegotiationMock{handlers: make(map[string]negotiated), t: t}
+}
+
+// Handle registers resp for requests accepting acceptType. The body of resp
+// is read once, so the response can be served any number of times, and its
+// Content-Type defaults to acceptType.
+func (m *ContentNegotiationMock) Handle(acceptType string, resp *http.Response) {
+	var body []byte
+	if resp.Body != nil {
+		var err error
+		if body, err = io.ReadAll(resp.Body); err != nil {
+			m.t.Errorf("reading %s response body: %v", acceptType, err)
+		}
+		_ = resp.Body.Close()
+	}
+	if resp.Header == nil {
+		resp.Header = make(http.Header)
+	}
+	if resp.Header.Get("Content-Type") == "" {
+		resp.Header.Set("Content-Type", acceptType)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.handlers[acceptType]; !ok {
+		m.types = append(m.types, acceptType)
+	}
+	m.handlers[acceptType] = negotiated{resp: resp, body: body}
+}
+
+// Transport returns a round tripper serving the negotiated responses.
+func (m *ContentNegotiationMock) Transport() http.RoundTripper {
+	return m
+}
+
+func (m *ContentNegotiationMock) RoundTrip(req *http.Request) (*http.Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.types) == 0 {
+		return nil, ErrNoMockResponse
+	}
+
+	accept := req.Header.Values("Accept")
+	if len(accept) == 0 {
+		return m.serve(m.types[0], req), nil
+	}
+	for _, r := range parseAccept(strings.Join(accept, ",")) {
+		for _, typ := range m.types {
+			if r.matches(typ) {
+				return m.serve(typ, req), nil
+			}
+		}
+	}
+
+	resp := NewMockResponse(WithStatus(http.StatusNotAcceptable))
+	resp.Request = req
+	return resp, nil
+}
+
+func (m *ContentNegotiationMock) serve(typ string, req *http.Request) *http.Response {
+	h := m.handlers[typ]
+	resp := *h.resp
+	resp.Header = h.resp.Header.Clone()
+	resp.Body = io.NopCloser(bytes.NewReader(h.body))
+	resp.ContentLength = int64(len(h.body))
+	resp.Request = req
+	return &resp
+}
+
+// mediaRange is one entry of an Accept header.
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+func (r mediaRange) matches(contentType string) bool {
+	typ, subtype, _ := strings.Cut(contentType, "/")
+	return (r.typ == "*" || strings.EqualFold(r.typ, typ)) &&
+		(r.subtype == "*" || strings.EqualFold(r.subtype, subtype))
+}
+
+// parseAccept returns the acceptable media ranges of header, most preferred
+// first. Ranges with q=0 are dropped.
+func parseAccept(header string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		r := mediaRange{q: 1}
+		r.typ, r.subtype, _ = strings.Cut(mediaType, "/")
+		if q, ok := params["q"]; ok {
+			if r.q, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		if r.q > 0 {
+			ranges = append(ranges, r)
+		}
+	}
+	slices.SortStableFunc(ranges, func(a, b mediaRange) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		}
+		return 0
+	})
+	return ranges
+}
@@ -0,0 +1,51 @@
+package roundtrip
+
+import (
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestContentNegotiationMock(t *testing.T) {
+	mock := NewContentNegotiationMock(t)
+	mock.Handle("application/json", NewMockResponse(WithBody([]byte(`{"name":"gopher"}`))))
+	mock.Handle("application/xml", NewMockResponse(WithBody([]byte(`<name>gopher</name>`))))
+	client := &http.Client{Transport: mock.Transport()}
+
+	tests := []struct {
+		name       string
+		accept     []string
+		wantStatus int
+		wantType   string
+		wantBody   string
+	}{
+		{"json", []string{"application/json"}, http.StatusOK, "application/json", `{"name":"gopher"}`},
+		{"xml", []string{"application/xml"}, http.StatusOK, "application/xml", `<name>gopher</name>`},
+		{"default without accept", nil, http.StatusOK, "application/json", `{"name":"gopher"}`},
+		{"quality values", []string{"application/json;q=0.5, application/xml"}, http.StatusOK, "application/xml", `<name>gopher</name>`},
+		{"wildcard", []string{"text/html, application/*;q=0.8"}, http.StatusOK, "application/json", `{"name":"gopher"}`},
+		{"not acceptable", []string{"text/html"}, http.StatusNotAcceptable, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "https://example.com/users/1", nil)
+			for _, a := range tt.accept {
+				req.Header.Add("Accept", a)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+			if got := resp.Header.Get("Content-Type"); got != tt.wantType {
+				t.Errorf("expected Content-Type '%s', got '%s'", tt.wantType, got)
+			}
+			if string(body) != tt.wantBody {
+				t.Errorf("expected body '%s', got '%s'", tt.wantBody, body)
+			}
+		})
+	}
+}
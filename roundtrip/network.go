@@ -0,0 +1,91 @@
+package roundtrip
+
+import (
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// NetworkOpts configures the round tripper returned by
+// NewNetworkErrorSimulator.
+type NetworkOpts func(*networkErrorSimulator)
+
+type networkErrorSimulator struct {
+	base http.RoundTripper
+
+	packetLoss    float64
+	latencyMean   time.Duration
+	latencyStddev time.Duration
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// NewNetworkErrorSimulator wraps base and injects connection resets and
+// latency according to opts. Without WithNetworkSeed the random source is
+// seeded from the current time.
+func NewNetworkErrorSimulator(base http.RoundTripper, opts ...NetworkOpts) http.RoundTripper {
+	n := &networkErrorSimulator{
+		base: base,
+		rnd:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for _, o := range opts {
+		o(n)
+	}
+	return n
+}
+
+// WithPacketLoss makes a rate fraction (0 to 1) of requests fail with a
+// connection reset error instead of reaching base.
+func WithPacketLoss(rate float64) NetworkOpts {
+	return func(n *networkErrorSimulator) {
+		n.packetLoss = rate
+	}
+}
+
+// WithLatencyVariance delays every request by a normally distributed duration
+// with the given mean and standard deviation. Negative samples are clamped to
+// zero.
+func WithLatencyVariance(mean, stddev time.Duration) NetworkOpts {
+	return func(n *networkErrorSimulator) {
+		n.latencyMean = mean
+		n.latencyStddev = stddev
+	}
+}
+
+// WithNetworkSeed seeds the random source so a test run can be reproduced.
+func WithNetworkSeed(seed int64) NetworkOpts {
+	return func(n *networkErrorSimulator) {
+		n.rnd = rand.New(rand.NewSource(seed))
+	}
+}
+
+func (n *networkErrorSimulator) RoundTrip(req *http.Request) (*http.Response, error) {
+	n.mu.Lock()
+	drop := n.packetLoss > 0 && n.rnd.Float64() < n.packetLoss
+	latency := n.latencyMean + time.Duration(n.rnd.NormFloat64()*float64(n.latencyStddev))
+	n.mu.Unlock()
+
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+
+	if drop {
+		return nil, &net.OpError{
+			Op:  "read",
+			Net: "tcp",
+			Err: os.NewSyscallError("read", syscall.ECONNRESET),
+		}
+	}
+	return n.base.RoundTrip(req)
+}
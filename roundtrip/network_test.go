@@ -0,0 +1,72 @@
+package roundtrip
+
+import (
+	"errors"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// outcomes sends n requests through rt and reports which ones failed.
+func outcomes(t *testing.T, rt http.RoundTripper, n int) []bool {
+	t.Helper()
+	failed := make([]bool, n)
+	for i := range failed {
+		req, _ := http.NewRequest("GET", "https://example.com/", nil)
+		_, err := rt.RoundTrip(req)
+		if err != nil && !errors.Is(err, syscall.ECONNRESET) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		failed[i] = err != nil
+	}
+	return failed
+}
+
+// alwaysOK is a base transport that never runs out of responses.
+type alwaysOK struct{}
+
+func (alwaysOK) RoundTrip(*http.Request) (*http.Response, error) {
+	return NewMockResponse(), nil
+}
+
+func TestNewNetworkErrorSimulator_PacketLoss(t *testing.T) {
+	rt := NewNetworkErrorSimulator(alwaysOK{}, WithPacketLoss(0.3), WithNetworkSeed(42))
+	failed := 0
+	for _, f := range outcomes(t, rt, 1000) {
+		if f {
+			failed++
+		}
+	}
+	if failed < 200 || failed > 400 {
+		t.Errorf("expected roughly 30%% of requests to fail, got %d/1000", failed)
+	}
+}
+
+func TestNewNetworkErrorSimulator_Reproducible(t *testing.T) {
+	a := outcomes(t, NewNetworkErrorSimulator(alwaysOK{}, WithPacketLoss(0.5), WithNetworkSeed(7)), 50)
+	b := outcomes(t, NewNetworkErrorSimulator(alwaysOK{}, WithPacketLoss(0.5), WithNetworkSeed(7)), 50)
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("expected identical outcomes for the same seed, diverged at request %d", i)
+		}
+	}
+}
+
+func TestNewNetworkErrorSimulator_NoLoss(t *testing.T) {
+	for i, f := range outcomes(t, NewNetworkErrorSimulator(alwaysOK{}), 20) {
+		if f {
+			t.Fatalf("expected no failures without packet loss, request %d failed", i)
+		}
+	}
+}
+
+func TestNewNetworkErrorSimulator_Latency(t *testing.T) {
+	rt := NewNetworkErrorSimulator(alwaysOK{}, WithLatencyVariance(10*time.Millisecond, time.Millisecond), WithNetworkSeed(1))
+
+	start := time.Now()
+	outcomes(t, rt, 5)
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("expected around 50ms of latency for 5 requests, got %v", elapsed)
+	}
+}
@@ -0,0 +1,51 @@
+package roundtrip
+
+import (
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestResponsePool(t *testing.T) {
+	var pool ResponsePool
+
+	resp := pool.Get(WithStatus(404), WithBody([]byte("stale")))
+	resp.Header.Set("X-Stale", "1")
+	if resp.StatusCode != 404 {
+		t.Fatalf("expected status 404, got %d", resp.StatusCode)
+	}
+	pool.Put(resp)
+
+	reused := pool.Get()
+	if reused.StatusCode != 200 {
+		t.Errorf("expected status 200, got %d", reused.StatusCode)
+	}
+	if len(reused.Header) != 0 {
+		t.Errorf("expected empty headers, got %v", reused.Header)
+	}
+	b, err := io.ReadAll(reused.Body)
+	if err != nil {
+		t.Fatalf("reading body: %v", err)
+	}
+	if len(b) != 0 {
+		t.Errorf("expected empty body, got '%s'", string(b))
+	}
+}
+
+func BenchmarkNewMockResponse(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		resp := NewMockResponse(WithStatus(http.StatusNoContent))
+		_ = resp
+	}
+}
+
+func BenchmarkResponsePool(b *testing.B) {
+	var pool ResponsePool
+	status := WithStatus(http.StatusNoContent)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		resp := pool.Get(status)
+		pool.Put(resp)
+	}
+}
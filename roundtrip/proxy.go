@@ -0,0 +1,96 @@
+package roundtrip
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ConnectionEstablished returns the response of a proxy accepting a CONNECT
+// tunnel.
+func ConnectionEstablished() *http.Response {
+	return proxyResponse(http.StatusOK, "200 Connection established", nil)
+}
+
+// ProxyAuthRequired returns the response of a proxy demanding basic
+// authentication for realm.
+func ProxyAuthRequired(realm string) *http.Response {
+	header := http.Header{"Proxy-Authenticate": {fmt.Sprintf("Basic realm=%q", realm)}}
+	status := http.StatusProxyAuthRequired
+	return proxyResponse(status, fmt.Sprintf("%d %s", status, http.StatusText(status)), header)
+}
+
+func proxyResponse(code int, status string, header http.Header) *http.Response {
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		StatusCode: code,
+		Status:     status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header,
+		Body:       io.NopCloser(bytes.NewReader(nil)),
+	}
+}
+
+type proxyRoundTripper struct {
+	responses map[string]proxyTarget
+}
+
+// proxyTarget is the response registered for a target, with its body read
+// into memory so every CONNECT gets a copy.
+type proxyTarget struct {
+	resp *http.Response
+	body []byte
+}
+
+// NewProxyRoundTripper simulates an HTTP proxy. CONNECT requests are answered
+// with the response registered for their target host:port, typically
+// ConnectionEstablished or ProxyAuthRequired; unknown targets get 502 Bad
+// Gateway. Requests other than CONNECT are rejected with 405. Every request
+// gets its own copy of the registered response. It panics if the body of a
+// response cannot be read.
+func NewProxyRoundTripper(responses map[string]*http.Response) http.RoundTripper {
+	p := &proxyRoundTripper{responses: make(map[string]proxyTarget, len(responses))}
+	for target, resp := range responses {
+		var body []byte
+		if resp.Body != nil {
+			var err error
+			if body, err = io.ReadAll(resp.Body); err != nil {
+				panic(fmt.Sprintf("roundtrip: reading response body for %s: %v", target, err))
+			}
+			_ = resp.Body.Close()
+		}
+		p.responses[target] = proxyTarget{resp: resp, body: body}
+	}
+	return p
+}
+
+func (p *proxyRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodConnect {
+		return p.reply(req, http.StatusMethodNotAllowed), nil
+	}
+
+	target := req.Host
+	if target == "" {
+		target = req.URL.Host
+	}
+	registered, ok := p.responses[target]
+	if !ok {
+		return p.reply(req, http.StatusBadGateway), nil
+	}
+	resp := *registered.resp
+	resp.Header = registered.resp.Header.Clone()
+	resp.Body = io.NopCloser(bytes.NewReader(registered.body))
+	resp.Request = req
+	return &resp, nil
+}
+
+func (p *proxyRoundTripper) reply(req *http.Request, code int) *http.Response {
+	resp := proxyResponse(code, fmt.Sprintf("%d %s", code, http.StatusText(code)), nil)
+	resp.Request = req
+	return resp
+}
@@ -0,0 +1,84 @@
+package roundtrip
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+func connectRequest(target string) *http.Request {
+	return &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: target},
+		Host:   target,
+		Header: make(http.Header),
+	}
+}
+
+func TestNewProxyRoundTripper(t *testing.T) {
+	proxy := NewProxyRoundTripper(map[string]*http.Response{
+		"open.example.com:443":    ConnectionEstablished(),
+		"private.example.com:443": ProxyAuthRequired("corp"),
+	})
+
+	tests := []struct {
+		name   string
+		req    *http.Request
+		status int
+	}{
+		{"tunnel established", connectRequest("open.example.com:443"), 200},
+		{"proxy auth required", connectRequest("private.example.com:443"), 407},
+		{"unknown target", connectRequest("unknown.example.com:443"), 502},
+		{"non CONNECT request", &http.Request{Method: "GET", URL: &url.URL{Scheme: "http", Host: "open.example.com"}}, 405},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := proxy.RoundTrip(tt.req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestNewProxyRoundTripper_Concurrent(t *testing.T) {
+	proxy := NewProxyRoundTripper(map[string]*http.Response{
+		"open.example.com:443": ConnectionEstablished(),
+	})
+
+	var wg sync.WaitGroup
+	resps := make([]*http.Response, 8)
+	for i := range resps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := connectRequest("open.example.com:443")
+			resp, err := proxy.RoundTrip(req)
+			if err != nil || resp.Request != req {
+				t.Errorf("expected a response to its own request, got %v, %v", resp, err)
+			}
+			resps[i] = resp
+		}()
+	}
+	wg.Wait()
+	if resps[0] == resps[1] {
+		t.Errorf("expected every CONNECT to get its own response")
+	}
+}
+
+func TestProxyAuthRequired(t *testing.T) {
+	resp := ProxyAuthRequired("corp")
+	if got := resp.Header.Get("Proxy-Authenticate"); got != `Basic realm="corp"` {
+		t.Errorf("unexpected Proxy-Authenticate header '%s'", got)
+	}
+}
+
+func TestConnectionEstablished(t *testing.T) {
+	if resp := ConnectionEstablished(); resp.Status != "200 Connection established" {
+		t.Errorf("unexpected status '%s'", resp.Status)
+	}
+}
@@ -0,0 +1,139 @@
+package roundtrip
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// ResourceServer simulates a REST resource of T kept in memory:
+//
+//	POST   /resources       stores the item in the body, 201 with a Location
+//	GET    /resources/{id}  returns the item
+//	PUT    /resources/{id}  replaces the item
+//	DELETE /resources/{id}  removes the item, 204
+//
+// Unknown ids result in 404 Not Found and undecodable bodies in 400 Bad
+// Request, which also fails the test.
+type ResourceServer[T any] struct {
+	mu     sync.Mutex
+	items  map[string]T
+	nextID int
+	mux    *http.ServeMux
+
+	t testing.TB
+}
+
+func NewResourceServer[T any](t testing.TB) *ResourceServer[T] {
+	s := &ResourceServer[T]{items: make(map[string]T), mux: http.NewServeMux(), t: t}
+	s.mux.HandleFunc("POST /resources", s.create)
+	s.mux.HandleFunc("GET /resources/{id}", s.get)
+	s.mux.HandleFunc("PUT /resources/{id}", s.update)
+	s.mux.HandleFunc("DELETE /resources/{id}", s.delete)
+	return s
+}
+
+// Store returns a copy of the stored items by id.
+func (s *ResourceServer[T]) Store() map[string]T {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return maps.Clone(s.items)
+}
+
+// Transport returns a round tripper serving requests from the resource.
+func (s *ResourceServer[T]) Transport() http.RoundTripper {
+	return s
+}
+
+func (s *ResourceServer[T]) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, req)
+	resp := rec.Result()
+	resp.Request = req
+	return resp, nil
+}
+
+func (s *ResourceServer[T]) create(w http.ResponseWriter, r *http.Request) {
+	item, ok := s.decode(w, r)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	s.nextID++
+	id := strconv.Itoa(s.nextID)
+	s.items[id] = item
+	s.mu.Unlock()
+
+	w.Header().Set("Location", "/resources/"+id)
+	s.encode(w, http.StatusCreated, item)
+}
+
+func (s *ResourceServer[T]) get(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	item, ok := s.items[r.PathValue("id")]
+	s.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	s.encode(w, http.StatusOK, item)
+}
+
+func (s *ResourceServer[T]) update(w http.ResponseWriter, r *http.Request) {
+	item, ok := s.decode(w, r)
+	if !ok {
+		return
+	}
+	id := r.PathValue("id")
+	s.mu.Lock()
+	_, exists := s.items[id]
+	if exists {
+		s.items[id] = item
+	}
+	s.mu.Unlock()
+	if !exists {
+		http.NotFound(w, r)
+		return
+	}
+	s.encode(w, http.StatusOK, item)
+}
+
+func (s *ResourceServer[T]) delete(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	s.mu.Lock()
+	_, exists := s.items[id]
+	delete(s.items, id)
+	s.mu.Unlock()
+	if !exists {
+		http.NotFound(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *ResourceServer[T]) decode(w http.ResponseWriter, r *http.Request) (T, bool) {
+	var item T
+	body := r.Body
+	if body == nil {
+		// a client request may come without a body, rejected like an empty one
+		body = http.NoBody
+	}
+	if err := json.NewDecoder(body).Decode(&item); err != nil {
+		s.t.Errorf("decoding %s %s: %v", r.Method, r.URL, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return item, false
+	}
+	return item, true
+}
+
+func (s *ResourceServer[T]) encode(w http.ResponseWriter, status int, item T) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(item); err != nil {
+		s.t.Errorf("encoding resource: %v", err)
+	}
+}
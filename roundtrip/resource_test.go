@@ -0,0 +1,100 @@
+package roundtrip
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+)
+
+type todo struct {
+	Title string `json:"title"`
+	Done  bool   `json:"done"`
+}
+
+func TestResourceServer(t *testing.T) {
+	server := NewResourceServer[todo](t)
+	client := &http.Client{Transport: server.Transport()}
+
+	do := func(method, url string, body any) *http.Response {
+		t.Helper()
+		var buf bytes.Buffer
+		if body != nil {
+			_ = json.NewEncoder(&buf).Encode(body)
+		}
+		req, _ := http.NewRequest(method, url, &buf)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, url, err)
+		}
+		return resp
+	}
+
+	// create
+	resp := do("POST", "https://api.example.com/resources", todo{Title: "write tests"})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+	location := resp.Header.Get("Location")
+	if location != "/resources/1" {
+		t.Fatalf("expected Location '/resources/1', got '%s'", location)
+	}
+
+	// read
+	resp = do("GET", "https://api.example.com"+location, nil)
+	var got todo
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decoding item: %v", err)
+	}
+	if resp.StatusCode != 200 || got.Title != "write tests" {
+		t.Fatalf("expected stored item, got %d %+v", resp.StatusCode, got)
+	}
+
+	// update
+	resp = do("PUT", "https://api.example.com"+location, todo{Title: "write tests", Done: true})
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200 on update, got %d", resp.StatusCode)
+	}
+	if !server.Store()["1"].Done {
+		t.Errorf("expected item to be updated in the store")
+	}
+
+	// delete
+	resp = do("DELETE", "https://api.example.com"+location, nil)
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204 on delete, got %d", resp.StatusCode)
+	}
+	if len(server.Store()) != 0 {
+		t.Errorf("expected empty store, got %v", server.Store())
+	}
+
+	// missing items
+	for _, method := range []string{"GET", "PUT", "DELETE"} {
+		var body any
+		if method == "PUT" {
+			body = todo{}
+		}
+		if resp := do(method, "https://api.example.com/resources/42", body); resp.StatusCode != 404 {
+			t.Errorf("%s: expected 404 for missing item, got %d", method, resp.StatusCode)
+		}
+	}
+}
+
+func TestResourceServer_InvalidBody(t *testing.T) {
+	rec := &recordingTB{TB: t}
+	client := &http.Client{Transport: NewResourceServer[todo](rec).Transport()}
+
+	for _, body := range []io.Reader{bytes.NewReader([]byte("{")), nil} {
+		resp, err := client.Post("https://api.example.com/resources", "application/json", body)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		if resp.StatusCode != 400 {
+			t.Errorf("expected 400, got %d", resp.StatusCode)
+		}
+	}
+	if len(rec.errors) != 2 {
+		t.Errorf("expected the invalid and the missing body to fail the test, got %v", rec.errors)
+	}
+}
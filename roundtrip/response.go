@@ -0,0 +1,152 @@
+package roundtrip
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math/rand"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// contentTypes maps file extensions to the Content-Type set by
+// ResponseFromFile. Unknown extensions fall back to mime.TypeByExtension.
+var contentTypes = map[string]string{
+	".json": "application/json",
+	".xml":  "application/xml",
+}
+
+// ResponseFromFile returns a 200 OK response whose body is read from path.
+// The Content-Type header is derived from the file extension when known; opts
+// are applied afterwards so they can override any of the defaults.
+func ResponseFromFile(path string, opts ...func(*http.Response)) (*http.Response, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading response file: %w", err)
+	}
+
+	resp := &http.Response{
+		StatusCode:    http.StatusOK,
+		Status:        fmt.Sprintf("%d %s", http.StatusOK, http.StatusText(http.StatusOK)),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		Header:        make(http.Header),
+		ContentLength: int64(len(body)),
+	}
+
+	ext := filepath.Ext(path)
+	contentType, ok := contentTypes[ext]
+	if !ok {
+		contentType = mime.TypeByExtension(ext)
+	}
+	if contentType != "" {
+		resp.Header.Set("Content-Type", contentType)
+	}
+
+	for _, o := range opts {
+		o(resp)
+	}
+	return resp, nil
+}
+
+// WithProto sets the protocol version of the response, e.g. WithProto(2, 0)
+// to simulate an HTTP/2 server.
+func WithProto(major, minor int) func(*http.Response) {
+	return func(r *http.Response) {
+		r.Proto = fmt.Sprintf("HTTP/%d.%d", major, minor)
+		r.ProtoMajor = major
+		r.ProtoMinor = minor
+	}
+}
+
+// WithTLSState sets Response.TLS, for testing code that inspects the
+// connection state, e.g. for certificate pinning.
+func WithTLSState(state *tls.ConnectionState) func(*http.Response) {
+	return func(r *http.Response) {
+		r.TLS = state
+	}
+}
+
+// WithInsecureTLS marks the response as received over TLS without any
+// certificate details.
+func WithInsecureTLS() func(*http.Response) {
+	return WithTLSState(&tls.ConnectionState{})
+}
+
+// WithContentLocation sets the Content-Location header, the canonical URL of
+// the returned resource.
+func WithContentLocation(url string) func(*http.Response) {
+	return func(r *http.Response) {
+		if r.Header == nil {
+			r.Header = make(http.Header)
+		}
+		r.Header.Set("Content-Location", url)
+	}
+}
+
+// WithBodyReadCloser uses rc as the body without copying it into memory, for
+// example a file opened with os.Open. Pass -1 as contentLength when it is
+// unknown. The caller must not share rc with other responses.
+func WithBodyReadCloser(rc io.ReadCloser, contentLength int64) func(*http.Response) {
+	return func(r *http.Response) {
+		setBody(r, rc)
+		r.ContentLength = contentLength
+	}
+}
+
+// WithStatusRange sets the status to one of codes, picked uniformly at random
+// each time the option is applied. Use WithStatusRangeFrom for a reproducible
+// choice.
+func WithStatusRange(codes ...int) func(*http.Response) {
+	return withStatusRange(rand.Intn, codes)
+}
+
+// WithStatusRangeFrom is like WithStatusRange, but picks the status from src.
+// src is not safe for concurrent use, so do not share it between goroutines.
+func WithStatusRangeFrom(src *rand.Rand, codes ...int) func(*http.Response) {
+	return withStatusRange(src.Intn, codes)
+}
+
+func withStatusRange(intn func(int) int, codes []int) func(*http.Response) {
+	if len(codes) == 0 {
+		panic("roundtrip: WithStatusRange needs at least one status code")
+	}
+	return func(r *http.Response) {
+		code := codes[intn(len(codes))]
+		r.StatusCode = code
+		r.Status = fmt.Sprintf("%d %s", code, http.StatusText(code))
+	}
+}
+
+// WithTrailer adds value to the trailer key of the response. Calling it
+// repeatedly accumulates trailers, like http.Header.Add.
+func WithTrailer(key, value string) func(*http.Response) {
+	return func(r *http.Response) {
+		if r.Trailer == nil {
+			r.Trailer = make(http.Header)
+		}
+		r.Trailer.Add(key, value)
+	}
+}
+
+// WithXML sets the body to v encoded with xml.Marshal, along with the
+// Content-Type and Content-Length headers. v is encoded once, and the option
+// panics if it cannot be.
+func WithXML(v any) func(*http.Response) {
+	body, err := xml.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("roundtrip: WithXML: %v", err))
+	}
+	return func(r *http.Response) {
+		WithBody(body)(r)
+		if r.Header == nil {
+			r.Header = make(http.Header)
+		}
+		r.Header.Set("Content-Type", "application/xml")
+		r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	}
+}
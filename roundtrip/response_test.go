@@ -0,0 +1,259 @@
+package roundtrip
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWithProto(t *testing.T) {
+	trt := &TestingRoundTripper{}
+	trt.AddMockResponse(NewMockResponse(WithProto(2, 0)))
+
+	client := &http.Client{Transport: trt}
+	resp, err := client.Get("https://example.com/")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if resp.Proto != "HTTP/2.0" {
+		t.Errorf("expected proto 'HTTP/2.0', got '%s'", resp.Proto)
+	}
+	if resp.ProtoMajor != 2 || resp.ProtoMinor != 0 {
+		t.Errorf("expected proto version 2.0, got %d.%d", resp.ProtoMajor, resp.ProtoMinor)
+	}
+	if !resp.ProtoAtLeast(2, 0) {
+		t.Errorf("expected ProtoAtLeast(2, 0) to be true")
+	}
+}
+
+func TestResponseFromFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("writing fixture: %v", err)
+		}
+		return path
+	}
+
+	t.Run("reads body and sets content type from extension", func(t *testing.T) {
+		resp, err := ResponseFromFile(write("user.json", `{"name":"gopher"}`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.StatusCode != 200 {
+			t.Errorf("expected status 200, got %d", resp.StatusCode)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("expected Content-Type 'application/json', got '%s'", ct)
+		}
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("reading body: %v", err)
+		}
+		if string(b) != `{"name":"gopher"}` {
+			t.Errorf("unexpected body '%s'", string(b))
+		}
+		if resp.ContentLength != int64(len(b)) {
+			t.Errorf("expected ContentLength %d, got %d", len(b), resp.ContentLength)
+		}
+	})
+
+	t.Run("xml extension", func(t *testing.T) {
+		resp, err := ResponseFromFile(write("user.xml", "<user/>"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "application/xml" {
+			t.Errorf("expected Content-Type 'application/xml', got '%s'", ct)
+		}
+	})
+
+	t.Run("options override defaults", func(t *testing.T) {
+		resp, err := ResponseFromFile(write("missing.json", "{}"), WithStatus(404))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.StatusCode != 404 {
+			t.Errorf("expected status 404, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("missing file returns error", func(t *testing.T) {
+		_, err := ResponseFromFile(filepath.Join(dir, "does-not-exist.json"))
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("expected ErrNotExist, got %v", err)
+		}
+	})
+}
+
+func TestWithTLSState(t *testing.T) {
+	cert := &x509.Certificate{Raw: []byte("pinned")}
+	state := &tls.ConnectionState{
+		Version:          tls.VersionTLS13,
+		PeerCertificates: []*x509.Certificate{cert},
+	}
+	resp := NewMockResponse(WithTLSState(state))
+
+	if resp.TLS != state {
+		t.Fatalf("expected TLS state to be set")
+	}
+	if resp.TLS.PeerCertificates[0] != cert {
+		t.Errorf("expected peer certificate to be preserved")
+	}
+}
+
+func TestWithInsecureTLS(t *testing.T) {
+	resp := NewMockResponse(WithInsecureTLS())
+
+	if resp.TLS == nil {
+		t.Fatalf("expected non-nil TLS state")
+	}
+	if len(resp.TLS.PeerCertificates) != 0 {
+		t.Errorf("expected no peer certificates, got %d", len(resp.TLS.PeerCertificates))
+	}
+}
+
+func TestWithContentLocation(t *testing.T) {
+	resp := NewMockResponse(WithStatus(201), WithContentLocation("https://example.com/items/42"))
+	if got := resp.Header.Get("Content-Location"); got != "https://example.com/items/42" {
+		t.Errorf("expected Content-Location 'https://example.com/items/42', got '%s'", got)
+	}
+
+	// a response without headers gets them initialized
+	bare := &http.Response{}
+	WithContentLocation("/items/1")(bare)
+	if got := bare.Header.Get("Content-Location"); got != "/items/1" {
+		t.Errorf("expected Content-Location '/items/1', got '%s'", got)
+	}
+}
+
+func TestWithBodyReadCloser(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "body.txt")
+	if err := os.WriteFile(path, []byte("from disk"), 0o600); err != nil {
+		t.Fatalf("writing fixture: %v", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("opening fixture: %v", err)
+	}
+
+	resp := NewMockResponse(WithBodyReadCloser(f, 9))
+	if resp.Body != f {
+		t.Errorf("expected the file to be used as body directly")
+	}
+	if resp.ContentLength != 9 {
+		t.Errorf("expected ContentLength 9, got %d", resp.ContentLength)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading body: %v", err)
+	}
+	if string(b) != "from disk" {
+		t.Errorf("expected 'from disk', got '%s'", string(b))
+	}
+	if err := resp.Body.Close(); err != nil {
+		t.Errorf("closing body: %v", err)
+	}
+}
+
+func TestWithStatusRange(t *testing.T) {
+	codes := []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+	seen := make(map[int]bool)
+	for i := 0; i < 200; i++ {
+		resp := NewMockResponse(WithStatusRange(codes...))
+		if resp.Status != fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode)) {
+			t.Fatalf("status line '%s' does not match code %d", resp.Status, resp.StatusCode)
+		}
+		seen[resp.StatusCode] = true
+	}
+	for _, code := range codes {
+		if !seen[code] {
+			t.Errorf("expected status %d to be picked at least once, got %v", code, seen)
+		}
+	}
+	if len(seen) != len(codes) {
+		t.Errorf("expected only %v, got %v", codes, seen)
+	}
+}
+
+func TestWithStatusRangeFrom(t *testing.T) {
+	pick := func() []int {
+		src := rand.New(rand.NewSource(7))
+		var got []int
+		for i := 0; i < 10; i++ {
+			got = append(got, NewMockResponse(WithStatusRangeFrom(src, 500, 502, 503)).StatusCode)
+		}
+		return got
+	}
+	if first, second := pick(), pick(); fmt.Sprint(first) != fmt.Sprint(second) {
+		t.Fatalf("expected the same statuses for the same seed, got %v and %v", first, second)
+	}
+}
+
+func TestWithTrailer(t *testing.T) {
+	trt := &TestingRoundTripper{}
+	trt.AddMockResponse(NewMockResponse(WithBody([]byte("payload")),
+		WithTrailer("Checksum", "abc"), WithTrailer("Server-Timing", "db;dur=3"), WithTrailer("Server-Timing", "app;dur=5")))
+
+	resp, err := (&http.Client{Transport: trt}).Get("https://example.com/stream")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		t.Fatalf("draining body: %v", err)
+	}
+	if got := resp.Trailer.Get("Checksum"); got != "abc" {
+		t.Errorf("expected trailer Checksum 'abc', got '%s'", got)
+	}
+	if got := resp.Trailer.Values("Server-Timing"); len(got) != 2 || got[1] != "app;dur=5" {
+		t.Errorf("expected accumulated Server-Timing trailers, got %v", got)
+	}
+}
+
+func TestWithXML(t *testing.T) {
+	type item struct {
+		XMLName xml.Name `xml:"item"`
+		ID      int      `xml:"id,attr"`
+		Name    string   `xml:"name"`
+	}
+	trt := &TestingRoundTripper{}
+	trt.AddMockResponse(NewMockResponse(WithXML(item{ID: 7, Name: "gopher"})))
+
+	resp, err := (&http.Client{Transport: trt}).Get("https://example.com/items/7")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if got := resp.Header.Get("Content-Type"); got != "application/xml" {
+		t.Errorf("expected Content-Type application/xml, got %q", got)
+	}
+	if resp.ContentLength != int64(len(body)) || resp.Header.Get("Content-Length") != fmt.Sprint(len(body)) {
+		t.Errorf("expected Content-Length %d, got %d and %q", len(body), resp.ContentLength, resp.Header.Get("Content-Length"))
+	}
+	var got item
+	if err := xml.Unmarshal(body, &got); err != nil {
+		t.Fatalf("unmarshaling %q: %v", body, err)
+	}
+	if got.ID != 7 || got.Name != "gopher" {
+		t.Errorf("expected item 7 gopher, got %+v", got)
+	}
+}
+
+func TestWithXML_Unmarshalable(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic for a value xml cannot encode")
+		}
+	}()
+	WithXML(make(chan int))
+}
@@ -1,45 +1,625 @@
 package roundtrip
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 var ErrNoMockResponse = errors.New("no mock response available")
 
+// DefaultQueueSize is the number of responses a TestingRoundTripper makes room
+// for up front. The queue grows beyond it unless WithQueueSize was called.
+const DefaultQueueSize = 1024
+
+// MockPanicError is returned by RoundTrip for a panic recovered with
+// WithPanicRecovery.
+type MockPanicError struct {
+	// Value is the value passed to panic.
+	Value any
+	// Stack is the stack trace of the panicking goroutine.
+	Stack []byte
+}
+
+func (e *MockPanicError) Error() string {
+	return fmt.Sprintf("mock panicked: %v", e.Value)
+}
+
+// NewMockResponse returns a 200 OK response with an empty body and headers,
+// with opts applied.
+func NewMockResponse(opts ...func(*http.Response)) *http.Response {
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     fmt.Sprintf("%d %s", http.StatusOK, http.StatusText(http.StatusOK)),
+		Body:       io.NopCloser(bytes.NewReader(nil)),
+		Header:     make(http.Header),
+	}
+	for _, o := range opts {
+		o(resp)
+	}
+	return resp
+}
+
+// WithStatus sets the status code and the matching status line.
+func WithStatus(status int) func(*http.Response) {
+	return func(r *http.Response) {
+		r.StatusCode = status
+		r.Status = fmt.Sprintf("%d %s", status, http.StatusText(status))
+	}
+}
+
+// WithBody sets the body and its content length.
+func WithBody(body []byte) func(*http.Response) {
+	return func(r *http.Response) {
+		setBody(r, io.NopCloser(bytes.NewReader(body)))
+		r.ContentLength = int64(len(body))
+	}
+}
+
 type TestingRoundTripper struct {
-	responses []*http.Response
+	mu sync.Mutex
+
+	// responses is the queue, created on first use; index counts the
+	// responses taken off it. Unless queueSize is set it grows when full.
+	responses chan queuedResponse
+	queueSize int
 	index     int
+	// head is the entry taken off the queue whose expectation the last
+	// request did not meet, served before the queue, see ExpectInOrder
+	head *queuedResponse
+	// taken holds the responses taken off the queue, for WithTestCleanup
+	taken []*http.Response
+
+	requests []*http.Request
+	// logged is closed and replaced whenever a request is logged
+	logged         chan struct{}
+	bufferRequests bool
+	maxBodySize    int64
+	jar            http.CookieJar
+	now            func() time.Time
+	mapper         func(*http.Request) (*http.Response, bool)
+	authorized     RequestMatcher
+	callErrors     map[int]error
+	// idempotencyKeys maps every Idempotency-Key seen to the index of its
+	// request, see WithUniqueIdempotencyKeys
+	idempotencyKeys map[string]int
+	// conditionals are tried before the queue, see AddConditionalResponse
+	conditionals []conditionalResponse
+
+	// cleanedUp is set by the cleanup hook of WithTestCleanup
+	cleanedUp bool
+	// recoverPanics turns panics into MockPanicError, see WithPanicRecovery
+	recoverPanics bool
+
+	// concurrency barrier, see WithExpectedConcurrency
+	concurrency int
+	waiting     []chan struct{}
 
-	t *testing.T
+	// t is a testing.TB so tests of this package can record failures
+	t testing.TB
+}
+
+// queuedResponse is an entry of the response queue.
+type queuedResponse struct {
+	resp *http.Response
+	responseMeta
+	// matcher is the expectation the request must meet, nil for any request
+	matcher RequestMatcher
 }
 
 func (srt *TestingRoundTripper) WithTest(t *testing.T) *TestingRoundTripper {
-	srt.t = t
+	if t != nil {
+		srt.t = t
+	}
 	return srt
 }
 
+// WithMockResponses replaces the queued responses by responses. The queue is
+// grown beyond its size if needed, even one set with WithQueueSize, so this
+// never blocks.
 func (srt *TestingRoundTripper) WithMockResponses(responses []*http.Response) *TestingRoundTripper {
-	srt.responses = responses
+	srt.mu.Lock()
+	defer srt.mu.Unlock()
+	srt.responses = make(chan queuedResponse, max(srt.size(), len(responses)))
+	srt.head = nil
+	for _, resp := range responses {
+		srt.responses <- queuedResponse{resp: resp, responseMeta: takeMeta(resp)}
+	}
 	return srt
 }
 
+// AddMockResponse queues response. If the queue size was set with
+// WithQueueSize, it blocks while the queue is full, until a request takes a
+// response off it.
 func (srt *TestingRoundTripper) AddMockResponse(response *http.Response) *TestingRoundTripper {
-	srt.responses = append(srt.responses, response)
+	srt.enqueue(queuedResponse{resp: response, responseMeta: takeMeta(response)})
+	return srt
+}
+
+// enqueue adds e to the queue, growing it if it is full and has no fixed
+// size, see WithQueueSize.
+func (srt *TestingRoundTripper) enqueue(e queuedResponse) {
+	srt.mu.Lock()
+	queue := srt.queue()
+	if srt.queueSize == 0 {
+		defer srt.mu.Unlock()
+		if len(queue) == cap(queue) {
+			queue = make(chan queuedResponse, 2*cap(queue))
+			for len(srt.responses) > 0 {
+				queue <- <-srt.responses
+			}
+			srt.responses = queue
+		}
+		queue <- e
+		return
+	}
+	srt.mu.Unlock()
+	// sent without the lock, so RoundTrip can drain a full queue
+	queue <- e
+}
+
+// WithQueueSize fixes the queue size to n, so AddMockResponse blocks while n
+// responses are queued instead of growing the queue. A producer goroutine can
+// thus generate responses lazily, staying at most n ahead of the requests.
+// RoundTrip does not wait for the producer: an empty queue still fails the
+// request. It panics if n is not positive or responses have been queued
+// already.
+func (srt *TestingRoundTripper) WithQueueSize(n int) *TestingRoundTripper {
+	if n <= 0 {
+		panic("roundtrip: WithQueueSize needs a positive size")
+	}
+	srt.mu.Lock()
+	defer srt.mu.Unlock()
+	if len(srt.responses) > 0 || srt.head != nil {
+		panic("roundtrip: WithQueueSize called after responses were queued")
+	}
+	srt.queueSize, srt.responses = n, nil
+	return srt
+}
+
+// size returns the queue size to start with.
+func (srt *TestingRoundTripper) size() int {
+	if srt.queueSize > 0 {
+		return srt.queueSize
+	}
+	return DefaultQueueSize
+}
+
+// queue returns the response queue, creating it on first use. srt.mu must be
+// held.
+func (srt *TestingRoundTripper) queue() chan queuedResponse {
+	if srt.responses == nil {
+		srt.responses = make(chan queuedResponse, srt.size())
+	}
+	return srt.responses
+}
+
+// ExpectInOrder queues the response of every expectation together with its
+// matcher: a request served from the queue gets the response only if it
+// matches. A request out of order fails the test given to WithTest and gets
+// ErrUnexpectedRequest, and the expectation stays in place for the next
+// request. Requests answered otherwise, e.g. by WithResponseMapper, are not
+// checked. Unlike a plain queue, this validates what was requested, not only
+// that something was.
+func (srt *TestingRoundTripper) ExpectInOrder(expectations ...RequestExpectation) *TestingRoundTripper {
+	for _, e := range expectations {
+		srt.enqueue(queuedResponse{resp: e.Response, responseMeta: takeMeta(e.Response), matcher: e.Matcher})
+	}
+	return srt
+}
+
+// ErrorAtIndex makes the i-th call, counting from 0, return (nil, err). The
+// response queued for that call, if any, is consumed and dropped, so the
+// following calls get the responses queued after it.
+func (srt *TestingRoundTripper) ErrorAtIndex(i int, err error) *TestingRoundTripper {
+	srt.mu.Lock()
+	defer srt.mu.Unlock()
+	if srt.callErrors == nil {
+		srt.callErrors = make(map[int]error)
+	}
+	srt.callErrors[i] = err
+	return srt
+}
+
+// ConcurrentAdd queues response like AddMockResponse, but may be called from
+// any goroutine while requests are in flight, e.g. to enqueue the next page
+// once the current one has been requested.
+func (srt *TestingRoundTripper) ConcurrentAdd(response *http.Response) {
+	srt.AddMockResponse(response)
+}
+
+// WithTestCleanup registers a cleanup with the test given to WithTest that
+// closes the body of every queued response, handed out or not, so readers
+// left behind by the test see an error instead of racing with the next one.
+// Any RoundTrip after the cleanup panics, which surfaces goroutines that
+// outlive the test. It panics if no test was set.
+func (srt *TestingRoundTripper) WithTestCleanup() *TestingRoundTripper {
+	if srt.t == nil {
+		panic("roundtrip: WithTestCleanup requires WithTest")
+	}
+	srt.t.Cleanup(func() {
+		srt.mu.Lock()
+		defer srt.mu.Unlock()
+		srt.cleanedUp = true
+		for srt.take() != nil {
+			// move the queued responses to srt.taken
+		}
+		for _, resp := range srt.taken {
+			if resp.Body != nil {
+				_ = resp.Body.Close()
+			}
+		}
+	})
+	return srt
+}
+
+// WithPanicRecovery recovers a panic raised while serving a request, e.g. by a
+// response mapper or a matcher, and turns it into a *MockPanicError that
+// RoundTrip returns and reports to the test given to WithTest. Without it the
+// panic crashes the goroutine of the client making the request.
+func (srt *TestingRoundTripper) WithPanicRecovery() *TestingRoundTripper {
+	srt.recoverPanics = true
 	return srt
 }
 
-func (srt *TestingRoundTripper) RoundTrip(_ *http.Request) (*http.Response, error) {
-	if srt.index >= len(srt.responses) {
+// WithRequestBuffering reads every request body into memory before it is
+// logged, so both the request log and req.Body can be read afterwards.
+func (srt *TestingRoundTripper) WithRequestBuffering() *TestingRoundTripper {
+	srt.bufferRequests = true
+	return srt
+}
+
+// WithMaxRequestBodySize fails the test given to WithTest for every request
+// whose body is larger than limit bytes, by its Content-Length or, when that
+// is unknown, by reading the body. The request is still served.
+func (srt *TestingRoundTripper) WithMaxRequestBodySize(limit int64) *TestingRoundTripper {
+	srt.maxBodySize = limit
+	return srt
+}
+
+// WithUniqueIdempotencyKeys fails the test given to WithTest when two requests
+// carry the same Idempotency-Key, e.g. because a retry reused the key of a
+// request that did not fail. Requests without the header are ignored.
+func (srt *TestingRoundTripper) WithUniqueIdempotencyKeys() *TestingRoundTripper {
+	srt.idempotencyKeys = make(map[string]int)
+	return srt
+}
+
+// WithExpectedConcurrency holds every request until n requests are in flight
+// at the same time, then releases them together. Responses are handed out in
+// the order the requests arrived. A client that serializes its calls never
+// reaches the barrier, so the test blocks until the request context is done
+// or the test times out.
+func (srt *TestingRoundTripper) WithExpectedConcurrency(n int) *TestingRoundTripper {
+	srt.concurrency = n
+	return srt
+}
+
+// WithCookieJar stores the cookies of every Set-Cookie response header in
+// jar, like a browser would. Share jar with the http.Client so subsequent
+// requests carry the cookies.
+func (srt *TestingRoundTripper) WithCookieJar(jar http.CookieJar) *TestingRoundTripper {
+	srt.jar = jar
+	return srt
+}
+
+// WithClock replaces time.Now when checking response expiry, see WithExpiry.
+func (srt *TestingRoundTripper) WithClock(now func() time.Time) *TestingRoundTripper {
+	srt.now = now
+	return srt
+}
+
+// WithResponseMapper calls fn for every request before the queue is
+// consulted. If fn returns true, its response is returned and the queue is
+// left untouched; otherwise the next queued response is used. fn runs without
+// the round tripper's lock held, so it may add responses itself.
+func (srt *TestingRoundTripper) WithResponseMapper(fn func(*http.Request) (*http.Response, bool)) *TestingRoundTripper {
+	srt.mapper = fn
+	return srt
+}
+
+// WithAuthRequired answers every request not matching authorized with 401
+// Unauthorized, without consuming a queued response, e.g. with
+// WithBasicAuthRequired.
+func (srt *TestingRoundTripper) WithAuthRequired(authorized RequestMatcher) *TestingRoundTripper {
+	srt.authorized = authorized
+	return srt
+}
+
+// Remaining returns the number of queued responses not yet handed out.
+func (srt *TestingRoundTripper) Remaining() int {
+	srt.mu.Lock()
+	defer srt.mu.Unlock()
+	if srt.head != nil {
+		return len(srt.responses) + 1
+	}
+	return len(srt.responses)
+}
+
+// Requests returns the requests seen so far, in the order they were made.
+func (srt *TestingRoundTripper) Requests() []*http.Request {
+	srt.mu.Lock()
+	defer srt.mu.Unlock()
+	return append([]*http.Request(nil), srt.requests...)
+}
+
+func (srt *TestingRoundTripper) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	// checked first, as reporting to a finished test panics with a less
+	// helpful message
+	srt.mu.Lock()
+	cleanedUp := srt.cleanedUp
+	srt.mu.Unlock()
+	if cleanedUp {
+		panic("TestingRoundTripper used after test cleanup")
+	}
+	if srt.recoverPanics {
+		defer func() {
+			if r := recover(); r != nil {
+				panicErr := &MockPanicError{Value: r, Stack: debug.Stack()}
+				if srt.t != nil {
+					srt.t.Errorf("%s %s: %v\n%s", req.Method, req.URL, panicErr, panicErr.Stack)
+				}
+				resp, err = nil, panicErr
+			}
+		}()
+	}
+
+	if srt.maxBodySize > 0 && req.Body != nil && req.Body != http.NoBody {
+		srt.checkBodySize(req)
+	}
+	logged := req
+	if srt.bufferRequests && req.Body != nil {
+		if logged, err = bufferRequest(req); err != nil {
+			return nil, err
+		}
+	}
+
+	var mapped *http.Response
+	var ok bool
+	if srt.authorized != nil && !srt.authorized(req) {
+		mapped, ok = unauthorized(), true
+	} else if srt.mapper != nil {
+		mapped, ok = srt.mapper(req)
+	}
+
+	served, release, err := srt.dispatch(req, logged, mapped, ok)
+	if release != nil {
+		select {
+		case <-release:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	resp = served.resp
+	served.holdBack()
+	if srt.jar != nil && resp != nil {
+		if cookies := resp.Cookies(); len(cookies) > 0 {
+			srt.jar.SetCookies(req.URL, cookies)
+		}
+	}
+	return resp, err
+}
+
+// dispatch logs req as logged and picks its response: mapped if ok and not
+// expired, else a conditional or the next queued one. It also returns the
+// channel to wait on at the concurrency barrier, if any. The lock is released
+// even if a matcher panics.
+func (srt *TestingRoundTripper) dispatch(req, logged *http.Request, mapped *http.Response, ok bool) (queuedResponse, <-chan struct{}, error) {
+	srt.mu.Lock()
+	defer srt.mu.Unlock()
+	srt.requests = append(srt.requests, logged)
+	if srt.logged != nil {
+		close(srt.logged)
+		srt.logged = nil
+	}
+	srt.checkIdempotencyKey(req)
+	served := queuedResponse{resp: mapped}
+	if ok && mapped != nil {
+		// the mapper may return the same response again, so it keeps its
+		// metadata and a copy with the plain body is served
+		if mb, wrapped := mapped.Body.(*metaBody); wrapped {
+			if served.responseMeta = mb.meta; served.expired(srt.clock()) {
+				ok = false
+			} else {
+				resp := *mapped
+				resp.Body = mb.ReadCloser
+				served.resp = &resp
+			}
+		}
+	}
+	if callErr, failed := srt.callErrors[len(srt.requests)-1]; failed {
+		if !ok {
+			srt.take()
+		}
+		return queuedResponse{}, nil, callErr
+	}
+	if !ok {
+		served, ok = srt.conditional(req)
+	}
+	var err error
+	if !ok {
+		served, err = srt.next(req)
+	}
+	return served, srt.arrive(), err
+}
+
+// WaitForRequest blocks until a request whose URL contains matchURL has been
+// logged, including requests made before the call, and returns the first such
+// request. It returns ctx.Err() if ctx is done first.
+func (srt *TestingRoundTripper) WaitForRequest(ctx context.Context, matchURL string) (*http.Request, error) {
+	seen := 0
+	for {
+		srt.mu.Lock()
+		for ; seen < len(srt.requests); seen++ {
+			if req := srt.requests[seen]; strings.Contains(req.URL.String(), matchURL) {
+				srt.mu.Unlock()
+				return req, nil
+			}
+		}
+		if srt.logged == nil {
+			srt.logged = make(chan struct{})
+		}
+		logged := srt.logged
+		srt.mu.Unlock()
+
+		select {
+		case <-logged:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func unauthorized() *http.Response {
+	resp := NewMockResponse(WithStatus(http.StatusUnauthorized))
+	resp.Header.Set("WWW-Authenticate", `Basic realm="roundtrip"`)
+	return resp
+}
+
+// checkIdempotencyKey reports an Idempotency-Key seen before, see
+// WithUniqueIdempotencyKeys. srt.mu must be held.
+func (srt *TestingRoundTripper) checkIdempotencyKey(req *http.Request) {
+	key := req.Header.Get("Idempotency-Key")
+	if srt.idempotencyKeys == nil || key == "" {
+		return
+	}
+	i := len(srt.requests) - 1
+	if first, seen := srt.idempotencyKeys[key]; seen {
 		if srt.t != nil {
-			srt.t.Errorf("no mock response for request at index %d", srt.index)
+			srt.t.Errorf("request %d to %s reuses Idempotency-Key %q of request %d", i, req.URL, key, first)
 		}
-		return nil, ErrNoMockResponse
+		return
 	}
+	srt.idempotencyKeys[key] = i
+}
 
-	resp := srt.responses[srt.index]
-	srt.index++
+// next pops the next queued response that has not expired for req. If req
+// does not meet the expectation of the response, see ExpectInOrder, the
+// response stays at the head of the queue. srt.mu must be held.
+func (srt *TestingRoundTripper) next(req *http.Request) (queuedResponse, error) {
+	for {
+		e := srt.take()
+		if e == nil {
+			if srt.t != nil {
+				srt.t.Errorf("no mock response for request at index %d", srt.index)
+			}
+			return queuedResponse{}, ErrNoMockResponse
+		}
+		if e.expired(srt.clock()) {
+			continue
+		}
+		// kept at the head while the matcher runs, in case it panics
+		srt.head = e
+		if e.matcher != nil && !e.matcher(req) {
+			if srt.t != nil {
+				srt.t.Errorf("%s %s does not match expectation %d", req.Method, req.URL, srt.index-1)
+			}
+			return queuedResponse{}, ErrUnexpectedRequest
+		}
+		srt.head = nil
+		return *e, nil
+	}
+}
+
+// clock returns the current time for expiry checks, see WithClock.
+func (srt *TestingRoundTripper) clock() time.Time {
+	if srt.now != nil {
+		return srt.now()
+	}
+	return time.Now()
+}
+
+// take returns the next queue entry without waiting, or nil if the queue is
+// empty. srt.mu must be held.
+func (srt *TestingRoundTripper) take() *queuedResponse {
+	if e := srt.head; e != nil {
+		srt.head = nil
+		return e
+	}
+	select {
+	case e := <-srt.queue():
+		srt.index++
+		if e.resp != nil {
+			srt.taken = append(srt.taken, e.resp)
+		}
+		return &e
+	default:
+		return nil
+	}
+}
+
+// arrive registers a request at the concurrency barrier and returns the
+// channel it has to wait on, or nil when there is no barrier. Once the
+// barrier is full all waiting requests are released in arrival order.
+// srt.mu must be held.
+func (srt *TestingRoundTripper) arrive() <-chan struct{} {
+	if srt.concurrency <= 0 {
+		return nil
+	}
+	release := make(chan struct{})
+	srt.waiting = append(srt.waiting, release)
+	if len(srt.waiting) >= srt.concurrency {
+		for _, w := range srt.waiting {
+			close(w)
+		}
+		srt.waiting = nil
+	}
+	return release
+}
+
+// checkBodySize reports a request body larger than srt.maxBodySize. At most
+// one byte more than the limit is read, and req.Body is replaced so it still
+// yields the full body.
+func (srt *TestingRoundTripper) checkBodySize(req *http.Request) {
+	size := req.ContentLength
+	if size <= 0 {
+		head, err := io.ReadAll(io.LimitReader(req.Body, srt.maxBodySize+1))
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(head), req.Body), req.Body}
+		if err != nil {
+			return
+		}
+		size = int64(len(head))
+	}
+	if size > srt.maxBodySize && srt.t != nil {
+		srt.t.Errorf("%s %s has a body of %s%d bytes, limit is %d", req.Method, req.URL, atLeast(req.ContentLength), size, srt.maxBodySize)
+	}
+}
+
+func atLeast(contentLength int64) string {
+	if contentLength > 0 {
+		return ""
+	}
+	return "at least "
+}
+
+// bufferRequest reads the body of req into memory, replaces req.Body with a
+// fresh reader and returns a copy of req with its own body for the log.
+func bufferRequest(req *http.Request) (*http.Request, error) {
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(req.Body); err != nil {
+		return nil, fmt.Errorf("buffering request body: %w", err)
+	}
+	_ = req.Body.Close()
+
+	body := buf.Bytes()
+	getBody := func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.Body, _ = getBody()
+	req.GetBody = getBody
 
-	return resp, nil
+	logged := req.Clone(req.Context())
+	logged.Body, _ = getBody()
+	return logged, nil
 }
@@ -2,10 +2,16 @@ package roundtrip
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/cookiejar"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 // This test the TestingRoundTripper to simulate the sequence of HTTP responses
@@ -15,9 +21,9 @@ import (
 func TestTestingRoundTripper_RoundTrip(t *testing.T) {
 	trt := &TestingRoundTripper{}
 	trt.WithMockResponses([]*http.Response{
-		newMockResponse(WithStatus(401), WithBody([]byte("token expired"))),
-		newMockResponse(WithStatus(200), WithBody([]byte(`{"access_token":"newtok"}`))),
-		newMockResponse(WithStatus(200), WithBody([]byte("welcome"))),
+		NewMockResponse(WithStatus(401), WithBody([]byte("token expired"))),
+		NewMockResponse(WithStatus(200), WithBody([]byte(`{"access_token":"newtok"}`))),
+		NewMockResponse(WithStatus(200), WithBody([]byte("welcome"))),
 	})
 
 	client := &http.Client{Transport: trt}
@@ -75,13 +81,13 @@ func TestTestingRoundTripper_RoundTrip(t *testing.T) {
 func TestTestingRoundTripper_AddMockResponse(t *testing.T) {
 	t.Run("adds mock response with no previous MockResponses", func(t *testing.T) {
 		trt := &TestingRoundTripper{}
-		trt.AddMockResponse(newMockResponse(WithBody([]byte("response1"))))
+		trt.AddMockResponse(NewMockResponse(WithBody([]byte("response1"))))
 
 		if len(trt.responses) != 1 {
 			t.Errorf("expected 1 response, got %d", len(trt.responses))
 		}
 
-		b, err := io.ReadAll(trt.responses[0].Body)
+		b, err := io.ReadAll((<-trt.responses).resp.Body)
 		if err != nil {
 			t.Fatalf("reading body: %v", err)
 		}
@@ -91,15 +97,14 @@ func TestTestingRoundTripper_AddMockResponse(t *testing.T) {
 	})
 
 	t.Run("adds mock response with previous MockResponses", func(t *testing.T) {
-		trt := &TestingRoundTripper{
-			responses: []*http.Response{newMockResponse(WithBody([]byte("response1")))},
-		}
-		trt.AddMockResponse(newMockResponse(WithBody([]byte("response2"))))
+		trt := (&TestingRoundTripper{}).WithMockResponses([]*http.Response{NewMockResponse(WithBody([]byte("response1")))})
+		trt.AddMockResponse(NewMockResponse(WithBody([]byte("response2"))))
 
 		if len(trt.responses) != 2 {
 			t.Errorf("expected 2 responses, got %d", len(trt.responses))
 		}
-		b, err := io.ReadAll(trt.responses[1].Body)
+		<-trt.responses
+		b, err := io.ReadAll((<-trt.responses).resp.Body)
 		if err != nil {
 			t.Fatalf("reading body: %v", err)
 		}
@@ -112,21 +117,21 @@ func TestTestingRoundTripper_AddMockResponse(t *testing.T) {
 func TestTestingRoundTripper_WithMockResponses(t *testing.T) {
 	trt := &TestingRoundTripper{}
 	trt.WithMockResponses([]*http.Response{
-		newMockResponse(WithBody([]byte("response1"))),
-		newMockResponse(WithBody([]byte("response2"))),
+		NewMockResponse(WithBody([]byte("response1"))),
+		NewMockResponse(WithBody([]byte("response2"))),
 	})
 
 	if len(trt.responses) != 2 {
 		t.Errorf("expected 2 responses, got %d", len(trt.responses))
 	}
-	b, err := io.ReadAll(trt.responses[0].Body)
+	b, err := io.ReadAll((<-trt.responses).resp.Body)
 	if err != nil {
 		t.Fatalf("reading body: %v", err)
 	}
 	if string(b) != "response1" {
 		t.Errorf("expected first response 'response1', got '%s'", string(b))
 	}
-	b2, err2 := io.ReadAll(trt.responses[1].Body)
+	b2, err2 := io.ReadAll((<-trt.responses).resp.Body)
 	if err2 != nil {
 		t.Fatalf("reading body: %v", err2)
 	}
@@ -135,6 +140,68 @@ func TestTestingRoundTripper_WithMockResponses(t *testing.T) {
 	}
 }
 
+func TestTestingRoundTripper_AddMockResponse_BeyondDefaultQueueSize(t *testing.T) {
+	trt := &TestingRoundTripper{}
+	client := &http.Client{Transport: trt}
+	for i := 0; i < DefaultQueueSize+10; i++ {
+		trt.AddMockResponse(NewMockResponse(WithBody([]byte(fmt.Sprint(i)))))
+	}
+	if got := trt.Remaining(); got != DefaultQueueSize+10 {
+		t.Fatalf("expected %d queued responses, got %d", DefaultQueueSize+10, got)
+	}
+	for i := 0; i < DefaultQueueSize+10; i++ {
+		resp, err := client.Get("https://example.com/")
+		if err != nil {
+			t.Fatalf("request %d: unexpected error: %v", i, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != fmt.Sprint(i) {
+			t.Fatalf("request %d: expected body %d, got %q", i, i, body)
+		}
+	}
+}
+
+func TestTestingRoundTripper_WithQueueSize(t *testing.T) {
+	trt := (&TestingRoundTripper{}).WithQueueSize(2)
+	client := &http.Client{Transport: trt}
+
+	// the producer stays at most two responses ahead of the requests
+	produced := make(chan int, 5)
+	go func() {
+		for i := 0; i < 5; i++ {
+			trt.AddMockResponse(NewMockResponse(WithBody([]byte(fmt.Sprint(i)))))
+			produced <- i
+		}
+	}()
+	for i := 0; i < 5; i++ {
+		for len(produced) < min(i+1, 5) {
+			time.Sleep(time.Millisecond)
+		}
+		if got := len(produced) - i; got > 2 {
+			t.Fatalf("expected at most 2 responses ahead, got %d", got)
+		}
+		resp, err := client.Get("https://example.com/")
+		if err != nil {
+			t.Fatalf("request %d: unexpected error: %v", i, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != fmt.Sprint(i) {
+			t.Errorf("request %d: expected body %d, got %q", i, i, body)
+		}
+	}
+}
+
+func TestTestingRoundTripper_WithQueueSize_AfterQueueing(t *testing.T) {
+	trt := &TestingRoundTripper{}
+	trt.AddMockResponse(NewMockResponse())
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic when resizing a non-empty queue")
+		}
+	}()
+	trt.WithQueueSize(10)
+}
+
 func TestTestingRoundTripper_WithTest(t *testing.T) {
 	trt := &TestingRoundTripper{}
 	trt.WithTest(t)
@@ -153,31 +220,509 @@ func TestTestingRoundTripper_WithTest(t *testing.T) {
 	}
 }
 
-func newMockResponse(opts ...func(*http.Response)) *http.Response {
-	resp := &http.Response{
-		StatusCode:    200,
-		Status:        fmt.Sprintf("%d %s", 200, http.StatusText(200)),
-		Body:          io.NopCloser(bytes.NewReader(nil)),
-		Header:        make(http.Header),
-		ContentLength: 0,
-		Request:       nil,
+func TestTestingRoundTripper_Requests(t *testing.T) {
+	trt := &TestingRoundTripper{}
+	trt.WithMockResponses([]*http.Response{NewMockResponse(), NewMockResponse()})
+
+	client := &http.Client{Transport: trt}
+	_, _ = client.Get("https://example.com/first")
+	_, _ = client.Get("https://example.com/second")
+
+	reqs := trt.Requests()
+	if len(reqs) != 2 {
+		t.Fatalf("expected 2 logged requests, got %d", len(reqs))
+	}
+	if reqs[0].URL.Path != "/first" || reqs[1].URL.Path != "/second" {
+		t.Errorf("unexpected request order: %s, %s", reqs[0].URL, reqs[1].URL)
+	}
+}
+
+func TestTestingRoundTripper_WithRequestBuffering(t *testing.T) {
+	trt := &TestingRoundTripper{}
+	trt.WithRequestBuffering().AddMockResponse(NewMockResponse())
+
+	req, _ := http.NewRequest("POST", "https://example.com/upload", bytes.NewReader([]byte("payload")))
+	if _, err := trt.RoundTrip(req); err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+
+	// the original request body is still readable
+	b, err := io.ReadAll(req.Body)
+	if err != nil {
+		t.Fatalf("reading request body: %v", err)
+	}
+	if string(b) != "payload" {
+		t.Errorf("expected request body 'payload', got '%s'", string(b))
+	}
+
+	// and so is the logged copy
+	logged, err := io.ReadAll(trt.Requests()[0].Body)
+	if err != nil {
+		t.Fatalf("reading logged body: %v", err)
+	}
+	if string(logged) != "payload" {
+		t.Errorf("expected logged body 'payload', got '%s'", string(logged))
+	}
+}
+
+func TestTestingRoundTripper_WithExpectedConcurrency(t *testing.T) {
+	t.Run("releases once all requests are in flight", func(t *testing.T) {
+		trt := &TestingRoundTripper{}
+		trt.WithExpectedConcurrency(3).WithMockResponses([]*http.Response{
+			NewMockResponse(), NewMockResponse(), NewMockResponse(),
+		})
+		client := &http.Client{Transport: trt}
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 3)
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := client.Get("https://example.com/parallel")
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}
+	})
+
+	t.Run("blocks serial requests", func(t *testing.T) {
+		trt := &TestingRoundTripper{}
+		trt.WithExpectedConcurrency(2).WithMockResponses([]*http.Response{NewMockResponse(), NewMockResponse()})
+		client := &http.Client{Transport: trt}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		req, _ := http.NewRequestWithContext(ctx, "GET", "https://example.com/serial", nil)
+		_, err := client.Do(req)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected serial request to time out at the barrier, got %v", err)
+		}
+	})
+}
+
+func TestTestingRoundTripper_WithCookieJar(t *testing.T) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("creating jar: %v", err)
+	}
+	login := NewMockResponse()
+	login.Header.Add("Set-Cookie", "session=abc123; Path=/")
+
+	trt := &TestingRoundTripper{}
+	trt.WithCookieJar(jar).WithMockResponses([]*http.Response{login, NewMockResponse()})
+	// the client deliberately has no jar of its own populating it
+	client := &http.Client{Transport: trt}
+
+	if _, err := client.Get("https://example.com/login"); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	u := trt.Requests()[0].URL
+	cookies := jar.Cookies(u)
+	if len(cookies) != 1 || cookies[0].Name != "session" || cookies[0].Value != "abc123" {
+		t.Fatalf("expected session cookie in jar, got %v", cookies)
+	}
+
+	client.Jar = jar
+	if _, err := client.Get("https://example.com/profile"); err != nil {
+		t.Fatalf("profile request failed: %v", err)
 	}
-	for _, o := range opts {
-		o(resp)
+	if got := trt.Requests()[1].Header.Get("Cookie"); got != "session=abc123" {
+		t.Errorf("expected cookie header 'session=abc123', got '%s'", got)
 	}
-	return resp
 }
 
-func WithStatus(status int) func(*http.Response) {
-	return func(r *http.Response) {
-		r.StatusCode = status
-		r.Status = fmt.Sprintf("%d %s", status, http.StatusText(status))
+func TestTestingRoundTripper_Remaining(t *testing.T) {
+	trt := &TestingRoundTripper{}
+	trt.WithMockResponses([]*http.Response{NewMockResponse(), NewMockResponse(), NewMockResponse(), NewMockResponse()})
+	client := &http.Client{Transport: trt}
+
+	if got := trt.Remaining(); got != 4 {
+		t.Fatalf("expected 4 remaining, got %d", got)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = client.Get("https://example.com/")
+		}()
 	}
+	wg.Wait()
+
+	if got := trt.Remaining(); got != 2 {
+		t.Fatalf("expected 2 remaining after half the mocks were consumed, got %d", got)
+	}
+}
+
+func TestTestingRoundTripper_WaitForRequest(t *testing.T) {
+	t.Run("returns once the request is made", func(t *testing.T) {
+		trt := &TestingRoundTripper{}
+		trt.WithMockResponses([]*http.Response{NewMockResponse(), NewMockResponse()})
+		client := &http.Client{Transport: trt}
+
+		go func() {
+			_, _ = client.Get("https://example.com/other")
+			_, _ = client.Get("https://example.com/webhook?id=1")
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		req, err := trt.WaitForRequest(ctx, "/webhook")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if req.URL.Query().Get("id") != "1" {
+			t.Errorf("expected the webhook request, got %s", req.URL)
+		}
+	})
+
+	t.Run("finds requests made before waiting", func(t *testing.T) {
+		trt := &TestingRoundTripper{}
+		trt.AddMockResponse(NewMockResponse())
+		_, _ = (&http.Client{Transport: trt}).Get("https://example.com/early")
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if _, err := trt.WaitForRequest(ctx, "/early"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("gives up when the context is done", func(t *testing.T) {
+		trt := &TestingRoundTripper{}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if _, err := trt.WaitForRequest(ctx, "/never"); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected DeadlineExceeded, got %v", err)
+		}
+	})
 }
 
-func WithBody(body []byte) func(*http.Response) {
-	return func(r *http.Response) {
-		r.Body = io.NopCloser(bytes.NewReader(body))
-		r.ContentLength = int64(len(body))
+func TestTestingRoundTripper_WithPanicRecovery(t *testing.T) {
+	t.Run("response mapper", func(t *testing.T) {
+		rec := &recordingTB{TB: t}
+		trt := (&TestingRoundTripper{t: rec}).WithPanicRecovery()
+		trt.WithResponseMapper(func(*http.Request) (*http.Response, bool) {
+			panic("boom")
+		})
+
+		req, _ := http.NewRequest("GET", "https://example.com/", nil)
+		_, err := trt.RoundTrip(req)
+		var panicErr *MockPanicError
+		if !errors.As(err, &panicErr) || panicErr.Value != "boom" {
+			t.Fatalf("expected MockPanicError for boom, got %v", err)
+		}
+		if len(rec.errors) != 1 || !strings.Contains(rec.errors[0], "mock panicked: boom") {
+			t.Errorf("expected the panic to be reported, got %v", rec.errors)
+		}
+	})
+
+	t.Run("matcher under lock", func(t *testing.T) {
+		rec := &recordingTB{TB: t}
+		trt := (&TestingRoundTripper{t: rec}).WithPanicRecovery()
+		panicked := false
+		trt.ExpectInOrder(
+			RequestExpectation{Matcher: func(*http.Request) bool {
+				if !panicked {
+					panicked = true
+					panic("boom")
+				}
+				return true
+			}, Response: NewMockResponse()},
+			RequestExpectation{Response: NewMockResponse(WithStatus(http.StatusAccepted))},
+		)
+
+		req, _ := http.NewRequest("GET", "https://example.com/", nil)
+		if _, err := trt.RoundTrip(req); err == nil {
+			t.Fatal("expected an error for the panicking matcher")
+		}
+		// the lock was released, so the next request is served, with the
+		// response the panicking one did not consume
+		resp, err := trt.RoundTrip(req)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %v, %v", resp, err)
+		}
+	})
+}
+
+func TestTestingRoundTripper_WithResponseMapper(t *testing.T) {
+	trt := &TestingRoundTripper{}
+	trt.AddMockResponse(NewMockResponse(WithStatus(http.StatusAccepted)))
+	trt.WithResponseMapper(func(req *http.Request) (*http.Response, bool) {
+		if req.URL.Path == "/health" {
+			return NewMockResponse(WithStatus(http.StatusNoContent)), true
+		}
+		return nil, false
+	})
+	client := &http.Client{Transport: trt}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get("https://example.com/health")
+		if err != nil || resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("expected mapped 204, got %v, %v", resp, err)
+		}
+	}
+	if trt.Remaining() != 1 {
+		t.Fatalf("expected mapped responses not to consume the queue, %d remaining", trt.Remaining())
+	}
+
+	resp, err := client.Get("https://example.com/jobs")
+	if err != nil || resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected queued 202, got %v, %v", resp, err)
+	}
+	if len(trt.Requests()) != 3 {
+		t.Errorf("expected all 3 requests to be logged, got %d", len(trt.Requests()))
+	}
+}
+
+func TestTestingRoundTripper_ConcurrentAdd(t *testing.T) {
+	trt := &TestingRoundTripper{}
+	trt.ConcurrentAdd(NewMockResponse(WithBody([]byte("page 1"))))
+	client := &http.Client{Transport: trt}
+
+	// enqueue page 2 once page 1 has been requested
+	added := make(chan struct{})
+	go func() {
+		defer close(added)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if _, err := trt.WaitForRequest(ctx, "page=1"); err == nil {
+			trt.ConcurrentAdd(NewMockResponse(WithBody([]byte("page 2"))))
+		}
+	}()
+
+	for page, want := range []string{"page 1", "page 2"} {
+		if page == 1 {
+			<-added
+		}
+		resp, err := client.Get(fmt.Sprintf("https://example.com/items?page=%d", page+1))
+		if err != nil {
+			t.Fatalf("page %d: unexpected error: %v", page+1, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != want {
+			t.Errorf("expected body '%s', got '%s'", want, body)
+		}
+	}
+
+	// adding while other goroutines make requests must not race
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			trt.ConcurrentAdd(NewMockResponse())
+		}()
+		go func() {
+			defer wg.Done()
+			_ = trt.Remaining()
+		}()
+	}
+	wg.Wait()
+	if trt.Remaining() != 10 {
+		t.Errorf("expected 10 queued responses, got %d", trt.Remaining())
+	}
+}
+
+func TestTestingRoundTripper_ExpectInOrder(t *testing.T) {
+	newTransport := func() *TestingRoundTripper {
+		return (&TestingRoundTripper{}).ExpectInOrder(
+			RequestExpectation{MatchAll(MatchMethod("POST"), MatchPath("/login")), NewMockResponse(WithStatus(http.StatusOK))},
+			RequestExpectation{MatchAll(MatchMethod("GET"), MatchPath("/profile")), NewMockResponse(WithStatus(http.StatusAccepted))},
+		)
+	}
+
+	t.Run("in order", func(t *testing.T) {
+		trt := newTransport().WithTest(t)
+		client := &http.Client{Transport: trt}
+		if resp, err := client.Post("https://example.com/login", "text/plain", nil); err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected login to succeed, got %v, %v", resp, err)
+		}
+		if resp, err := client.Get("https://example.com/profile"); err != nil || resp.StatusCode != http.StatusAccepted {
+			t.Fatalf("expected profile to succeed, got %v, %v", resp, err)
+		}
+	})
+
+	t.Run("out of order", func(t *testing.T) {
+		trt := newTransport()
+		req, _ := http.NewRequest("GET", "https://example.com/profile", nil)
+		if _, err := trt.RoundTrip(req); !errors.Is(err, ErrUnexpectedRequest) {
+			t.Fatalf("expected ErrUnexpectedRequest, got %v", err)
+		}
+		if trt.Remaining() != 2 {
+			t.Errorf("expected the rejected request not to consume a response, %d remaining", trt.Remaining())
+		}
+	})
+
+	t.Run("in order after a rejected request", func(t *testing.T) {
+		rec := &recordingTB{TB: t}
+		trt := newTransport()
+		trt.t = rec
+		client := &http.Client{Transport: trt}
+		_, _ = client.Get("https://example.com/profile")
+		if resp, err := client.Post("https://example.com/login", "text/plain", nil); err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected login to get its response, got %v, %v", resp, err)
+		}
+		if resp, err := client.Get("https://example.com/profile"); err != nil || resp.StatusCode != http.StatusAccepted {
+			t.Fatalf("expected profile to get its response, got %v, %v", resp, err)
+		}
+		if len(rec.errors) != 1 {
+			t.Errorf("expected only the rejected request to fail, got %v", rec.errors)
+		}
+	})
+
+	t.Run("mapped requests do not shift expectations", func(t *testing.T) {
+		rec := &recordingTB{TB: t}
+		trt := newTransport().WithResponseMapper(func(req *http.Request) (*http.Response, bool) {
+			return NewMockResponse(WithStatus(http.StatusNoContent)), req.URL.Path == "/health"
+		})
+		trt.t = rec
+		client := &http.Client{Transport: trt}
+		_, _ = client.Get("https://example.com/health")
+		if _, err := client.Get("https://example.com/profile"); !errors.Is(err, ErrUnexpectedRequest) {
+			t.Fatalf("expected ErrUnexpectedRequest, got %v", err)
+		}
+		if len(rec.errors) != 1 {
+			t.Errorf("expected the out of order request to fail, got %v", rec.errors)
+		}
+	})
+}
+
+func TestTestingRoundTripper_WithMaxRequestBodySize(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        []byte
+		knownLength bool
+		wantFail    bool
+	}{
+		{"within limit", []byte("small"), true, false},
+		{"content length over limit", bytes.Repeat([]byte("x"), 11), true, true},
+		{"unknown length within limit", []byte("small"), false, false},
+		{"unknown length over limit", bytes.Repeat([]byte("x"), 64), false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recordingTB{TB: t}
+			trt := (&TestingRoundTripper{t: rec}).WithMaxRequestBodySize(10).WithRequestBuffering()
+			trt.AddMockResponse(NewMockResponse())
+
+			var body io.Reader = bytes.NewReader(tt.body)
+			if !tt.knownLength {
+				// hide the reader type, so http.NewRequest cannot tell the length
+				body = io.MultiReader(body)
+			}
+			req, _ := http.NewRequest("POST", "https://example.com/upload", body)
+			if _, err := trt.RoundTrip(req); err != nil {
+				t.Fatalf("expected the request to be served, got %v", err)
+			}
+			if failed := len(rec.errors) > 0; failed != tt.wantFail {
+				t.Errorf("expected failure %v, got %v", tt.wantFail, rec.errors)
+			}
+			// the body is still delivered in full
+			got, _ := io.ReadAll(trt.Requests()[0].Body)
+			if !bytes.Equal(got, tt.body) {
+				t.Errorf("expected body of %d bytes, got %d", len(tt.body), len(got))
+			}
+		})
+	}
+}
+
+// closeRecorder records whether the body was closed.
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestTestingRoundTripper_WithTestCleanup(t *testing.T) {
+	served := &closeRecorder{Reader: strings.NewReader("served")}
+	queued := &closeRecorder{Reader: strings.NewReader("queued")}
+	trt := &TestingRoundTripper{}
+
+	t.Run("test", func(t *testing.T) {
+		trt.WithTest(t).WithTestCleanup()
+		trt.AddMockResponse(NewMockResponse(func(r *http.Response) { r.Body = served }))
+		trt.AddMockResponse(NewMockResponse(func(r *http.Response) { r.Body = queued }))
+
+		req, _ := http.NewRequest("GET", "https://example.com/", nil)
+		if _, err := trt.RoundTrip(req); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// the body is left unread, as by a goroutine outliving the test
+	})
+
+	if !served.closed || !queued.closed {
+		t.Errorf("expected all bodies to be closed, got served %v, queued %v", served.closed, queued.closed)
+	}
+	defer func() {
+		if r := recover(); r != "TestingRoundTripper used after test cleanup" {
+			t.Errorf("expected panic after cleanup, got %v", r)
+		}
+	}()
+	req, _ := http.NewRequest("GET", "https://example.com/", nil)
+	_, _ = trt.RoundTrip(req)
+}
+
+func TestTestingRoundTripper_WithUniqueIdempotencyKeys(t *testing.T) {
+	rec := &recordingTB{TB: t}
+	trt := (&TestingRoundTripper{t: rec}).WithUniqueIdempotencyKeys()
+	trt.WithMockResponses([]*http.Response{NewMockResponse(), NewMockResponse(), NewMockResponse(), NewMockResponse()})
+
+	for _, key := range []string{"key-1", "key-2", "", "key-1"} {
+		req, _ := http.NewRequest("POST", "https://example.com/payments", nil)
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		_, _ = trt.RoundTrip(req)
+	}
+
+	if len(rec.errors) != 1 || !strings.Contains(rec.errors[0], `"key-1" of request 0`) {
+		t.Fatalf("expected one failure for the reused key, got %v", rec.errors)
+	}
+}
+
+func TestTestingRoundTripper_ErrorAtIndex(t *testing.T) {
+	errReset := errors.New("connection reset")
+	trt := &TestingRoundTripper{}
+	trt.WithMockResponses([]*http.Response{
+		NewMockResponse(WithStatus(http.StatusOK)),
+		NewMockResponse(WithStatus(http.StatusAccepted)),
+		NewMockResponse(WithStatus(http.StatusCreated)),
+	})
+	trt.ErrorAtIndex(1, errReset)
+
+	want := []struct {
+		status int
+		err    error
+	}{
+		{http.StatusOK, nil},
+		{0, errReset},
+		{http.StatusCreated, nil},
+	}
+	for i, w := range want {
+		req, _ := http.NewRequest("GET", "https://example.com/poll", nil)
+		resp, err := trt.RoundTrip(req)
+		if err != w.err {
+			t.Fatalf("call %d: expected error %v, got %v", i, w.err, err)
+		}
+		if err == nil && resp.StatusCode != w.status {
+			t.Errorf("call %d: expected status %d, got %d", i, w.status, resp.StatusCode)
+		}
+		if err != nil && resp != nil {
+			t.Errorf("call %d: expected no response with the error", i)
+		}
 	}
 }
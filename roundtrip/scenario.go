@@ -0,0 +1,127 @@
+package roundtrip
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+var ErrUnexpectedRequest = errors.New("request does not match the current scenario step")
+
+type scenarioStep struct {
+	matcher    RequestMatcher
+	response   *http.Response
+	assertions []func(*http.Request)
+}
+
+// Scenario describes an ordered multi-step HTTP flow. Each request has to
+// match the matcher of the current step, which then serves its response.
+//
+// Steps are added with Step, or read like a script with NewScenario:
+//
+//	NewScenario(t).
+//		When(http.MethodGet, "/token").Return(401, expiredBody).
+//		When(http.MethodPost, "/refresh").Return(200, tokenBody).
+//		Install(client)
+type Scenario struct {
+	steps []scenarioStep
+
+	// pending is the matcher of a When still waiting for its Return
+	pending RequestMatcher
+	t       testing.TB
+}
+
+// NewScenario returns an empty scenario that reports misuse of When and
+// Return, and requests out of order once installed, to t.
+func NewScenario(t testing.TB) *Scenario {
+	return &Scenario{t: t}
+}
+
+// When starts a step matching requests with method and path. It must be
+// followed by Return.
+func (s *Scenario) When(method, path string) *Scenario {
+	if s.pending != nil {
+		s.t.Fatalf("scenario: When(%s, %s) follows a When without Return", method, path)
+	}
+	s.pending = MatchAll(MatchMethod(method), MatchPath(path))
+	return s
+}
+
+// Return completes the step started by When, serving status and body.
+func (s *Scenario) Return(status int, body []byte) *Scenario {
+	if s.pending == nil {
+		s.t.Fatalf("scenario: Return(%d) without When", status)
+	}
+	resp := NewMockResponse(WithStatus(status), WithBody(body))
+	s.pending, s.steps = nil, append(s.steps, scenarioStep{matcher: s.pending, response: resp})
+	return s
+}
+
+// Install sets the transport of client to a TestingRoundTripper serving the
+// steps in order, see ExpectInOrder, and returns it for further assertions.
+func (s *Scenario) Install(client *http.Client) *TestingRoundTripper {
+	if s.pending != nil {
+		s.t.Fatalf("scenario: Install after a When without Return")
+	}
+	expectations := make([]RequestExpectation, len(s.steps))
+	for i, step := range s.steps {
+		expectations[i] = RequestExpectation{Matcher: step.matchAndAssert, Response: step.response}
+	}
+	srt := (&TestingRoundTripper{t: s.t}).ExpectInOrder(expectations...)
+	client.Transport = srt
+	return srt
+}
+
+// Step appends a step to the scenario. assertions run against the request
+// that matched the step.
+func (s *Scenario) Step(matcher RequestMatcher, response *http.Response, assertions ...func(*http.Request)) *Scenario {
+	s.steps = append(s.steps, scenarioStep{matcher: matcher, response: response, assertions: assertions})
+	return s
+}
+
+// Play returns a transport that walks through the steps of the scenario,
+// failing t when a request arrives out of order or after the last step.
+func (s *Scenario) Play(t testing.TB) http.RoundTripper {
+	return &scenarioPlayer{steps: s.steps, t: t}
+}
+
+type scenarioPlayer struct {
+	mu    sync.Mutex
+	steps []scenarioStep
+	index int
+
+	t testing.TB
+}
+
+// matchAndAssert matches req and runs the step's assertions on a match.
+func (step scenarioStep) matchAndAssert(req *http.Request) bool {
+	if !step.matcher(req) {
+		return false
+	}
+	for _, assert := range step.assertions {
+		assert(req)
+	}
+	return true
+}
+
+func (p *scenarioPlayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.index >= len(p.steps) {
+		p.t.Errorf("no scenario step for %s %s at index %d", req.Method, req.URL, p.index)
+		return nil, ErrNoMockResponse
+	}
+	step := p.steps[p.index]
+	if !step.matcher(req) {
+		p.t.Errorf("%s %s does not match scenario step %d", req.Method, req.URL, p.index)
+		return nil, ErrUnexpectedRequest
+	}
+	p.index++
+
+	for _, assert := range step.assertions {
+		assert(req)
+	}
+	return step.response, nil
+}
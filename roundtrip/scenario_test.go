@@ -0,0 +1,116 @@
+package roundtrip
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestScenario(t *testing.T) {
+	t.Run("plays steps in order", func(t *testing.T) {
+		var refreshAuth string
+		scenario := (&Scenario{}).
+			Step(MatchPath("/protected"), NewMockResponse(WithStatus(401))).
+			Step(MatchAll(MatchMethod("POST"), MatchPath("/refresh")), NewMockResponse(), func(req *http.Request) {
+				refreshAuth = req.Header.Get("Authorization")
+			}).
+			Step(MatchPath("/protected"), NewMockResponse(WithStatus(200)))
+		client := &http.Client{Transport: scenario.Play(t)}
+
+		resp1, err := client.Get("https://example.com/protected")
+		if err != nil || resp1.StatusCode != 401 {
+			t.Fatalf("expected 401, got %v, %v", resp1, err)
+		}
+		req, _ := http.NewRequest("POST", "https://example.com/refresh", nil)
+		req.Header.Set("Authorization", "Bearer refresh-token")
+		if _, err := client.Do(req); err != nil {
+			t.Fatalf("refresh failed: %v", err)
+		}
+		resp3, err := client.Get("https://example.com/protected")
+		if err != nil || resp3.StatusCode != 200 {
+			t.Fatalf("expected 200, got %v, %v", resp3, err)
+		}
+		if refreshAuth != "Bearer refresh-token" {
+			t.Errorf("expected step assertion to see the refresh request, got '%s'", refreshAuth)
+		}
+	})
+
+	t.Run("fails on out of order request", func(t *testing.T) {
+		rec := &recordingTB{TB: t}
+		scenario := (&Scenario{}).
+			Step(MatchPath("/first"), NewMockResponse()).
+			Step(MatchPath("/second"), NewMockResponse())
+		client := &http.Client{Transport: scenario.Play(rec)}
+
+		_, err := client.Get("https://example.com/second")
+		if !errors.Is(err, ErrUnexpectedRequest) {
+			t.Errorf("expected ErrUnexpectedRequest, got %v", err)
+		}
+		if len(rec.errors) != 1 {
+			t.Errorf("expected 1 test failure, got %v", rec.errors)
+		}
+	})
+
+	t.Run("fails after the last step", func(t *testing.T) {
+		rec := &recordingTB{TB: t}
+		scenario := (&Scenario{}).Step(MatchPath("/only"), NewMockResponse())
+		client := &http.Client{Transport: scenario.Play(rec)}
+
+		_, _ = client.Get("https://example.com/only")
+		_, err := client.Get("https://example.com/only")
+		if !errors.Is(err, ErrNoMockResponse) {
+			t.Errorf("expected ErrNoMockResponse, got %v", err)
+		}
+		if len(rec.errors) != 1 {
+			t.Errorf("expected 1 test failure, got %v", rec.errors)
+		}
+	})
+}
+
+func TestNewScenario(t *testing.T) {
+	client := &http.Client{}
+	trt := NewScenario(t).
+		When(http.MethodGet, "/token").Return(401, []byte(`{"error":"expired"}`)).
+		When(http.MethodPost, "/refresh").Return(200, []byte(`{"token":"fresh"}`)).
+		When(http.MethodGet, "/data").Return(200, []byte(`[1,2,3]`)).
+		Install(client)
+
+	steps := []struct {
+		method, path string
+		status       int
+		body         string
+	}{
+		{"GET", "/token", 401, `{"error":"expired"}`},
+		{"POST", "/refresh", 200, `{"token":"fresh"}`},
+		{"GET", "/data", 200, `[1,2,3]`},
+	}
+	for _, step := range steps {
+		req, _ := http.NewRequest(step.method, "https://api.example.com"+step.path, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", step.method, step.path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != step.status || string(body) != step.body {
+			t.Errorf("%s %s: expected %d '%s', got %d '%s'", step.method, step.path, step.status, step.body, resp.StatusCode, body)
+		}
+	}
+	if trt.Remaining() != 0 {
+		t.Errorf("expected every step to be served, %d remaining", trt.Remaining())
+	}
+}
+
+func TestNewScenario_OutOfOrder(t *testing.T) {
+	client := &http.Client{}
+	scenario := NewScenario(t).
+		When(http.MethodGet, "/token").Return(401, nil).
+		When(http.MethodGet, "/data").Return(200, nil)
+	// detach the scenario from t so the expected failure does not fail us
+	scenario.t = nil
+	scenario.Install(client)
+
+	if _, err := client.Get("https://api.example.com/data"); !errors.Is(err, ErrUnexpectedRequest) {
+		t.Fatalf("expected ErrUnexpectedRequest, got %v", err)
+	}
+}
@@ -0,0 +1,24 @@
+package roundtrip
+
+import (
+	"net/http"
+	"time"
+)
+
+// WithRequestTimeout makes a TestingRoundTripper hold the response back for d
+// before returning it, ignoring the request's context: the server has
+// processed the request, but the reply is slow to arrive. A caller whose
+// context deadline is shorter than d still receives the response, but sees
+// context.DeadlineExceeded from ctx.Err() once it has read it.
+func WithRequestTimeout(d time.Duration) func(*http.Response) {
+	return func(r *http.Response) {
+		metaOf(r).timeout = d
+	}
+}
+
+// holdBack waits for the delay set by WithRequestTimeout, if any.
+func (m responseMeta) holdBack() {
+	if m.timeout > 0 {
+		time.Sleep(m.timeout)
+	}
+}
@@ -0,0 +1,35 @@
+package roundtrip
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestWithRequestTimeout(t *testing.T) {
+	trt := &TestingRoundTripper{}
+	trt.AddMockResponse(NewMockResponse(WithBody([]byte("late")), WithRequestTimeout(30*time.Millisecond)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", "https://example.com/slow", nil)
+
+	start := time.Now()
+	resp, err := trt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("expected the response to be delivered, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("expected delivery to be delayed by 30ms, got %v", elapsed)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "late" {
+		t.Errorf("expected body 'late', got '%s'", body)
+	}
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		t.Errorf("expected DeadlineExceeded after reading the response, got %v", ctx.Err())
+	}
+}
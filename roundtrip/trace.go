@@ -0,0 +1,34 @@
+package roundtrip
+
+import (
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type traceRoundTripper struct {
+	base http.RoundTripper
+}
+
+// NewTraceRoundTripper wraps base and propagates the span in the request
+// context through the W3C Trace Context headers traceparent and tracestate.
+// Requests without a valid span context are passed on unchanged.
+func NewTraceRoundTripper(base http.RoundTripper) http.RoundTripper {
+	return &traceRoundTripper{base: base}
+}
+
+func (t *traceRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	sc := trace.SpanFromContext(req.Context()).SpanContext()
+	if !sc.IsValid() {
+		return t.base.RoundTrip(req)
+	}
+
+	// a RoundTripper must not modify the caller's request
+	traced := req.Clone(req.Context())
+	traced.Header.Set("traceparent", fmt.Sprintf("00-%s-%s-%s", sc.TraceID(), sc.SpanID(), sc.TraceFlags()))
+	if ts := sc.TraceState().String(); ts != "" {
+		traced.Header.Set("tracestate", ts)
+	}
+	return t.base.RoundTrip(traced)
+}
@@ -0,0 +1,72 @@
+package roundtrip
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+var traceparentFormat = regexp.MustCompile(`^00-[0-9a-f]{32}-[0-9a-f]{16}-[0-9a-f]{2}$`)
+
+func TestNewTraceRoundTripper(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	state, _ := trace.ParseTraceState("congo=t61rcWkgMzE")
+	parent := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+		TraceState: state,
+	})
+
+	t.Run("injects headers for a span", func(t *testing.T) {
+		ctx := trace.ContextWithSpanContext(context.Background(), parent)
+		ctx, span := noop.NewTracerProvider().Tracer("test").Start(ctx, "client")
+		defer span.End()
+
+		trt := &TestingRoundTripper{}
+		trt.AddMockResponse(NewMockResponse())
+		client := &http.Client{Transport: NewTraceRoundTripper(trt)}
+		req, _ := http.NewRequestWithContext(ctx, "GET", "https://example.com/", nil)
+		if _, err := client.Do(req); err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+
+		got := trt.Requests()[0].Header
+		want := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+		if got.Get("traceparent") != want {
+			t.Errorf("expected traceparent '%s', got '%s'", want, got.Get("traceparent"))
+		}
+		if !traceparentFormat.MatchString(got.Get("traceparent")) {
+			t.Errorf("traceparent '%s' does not match the W3C format", got.Get("traceparent"))
+		}
+		if got.Get("tracestate") != "congo=t61rcWkgMzE" {
+			t.Errorf("expected tracestate 'congo=t61rcWkgMzE', got '%s'", got.Get("tracestate"))
+		}
+		if req.Header.Get("traceparent") != "" {
+			t.Errorf("expected the caller's request to be left unmodified")
+		}
+	})
+
+	t.Run("omits headers without a span", func(t *testing.T) {
+		_, span := noop.NewTracerProvider().Tracer("test").Start(context.Background(), "client")
+		ctx := trace.ContextWithSpan(context.Background(), span)
+
+		trt := &TestingRoundTripper{}
+		trt.AddMockResponse(NewMockResponse())
+		client := &http.Client{Transport: NewTraceRoundTripper(trt)}
+		req, _ := http.NewRequestWithContext(ctx, "GET", "https://example.com/", nil)
+		if _, err := client.Do(req); err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+
+		got := trt.Requests()[0].Header
+		if got.Get("traceparent") != "" || got.Get("tracestate") != "" {
+			t.Errorf("expected no trace headers, got %v", got)
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package roundtrip
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// websocketGUID is the fixed GUID from RFC 6455 used to derive
+// Sec-WebSocket-Accept.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// upgradeBody is the body of a 101 Switching Protocols response. The client
+// end of a net.Pipe is the body itself; the server end is kept as the peer.
+type upgradeBody struct {
+	net.Conn
+	peer net.Conn
+}
+
+// UpgradePeer returns the server end of the connection behind a response
+// created with WithWebSocketUpgrade. Tests use it to exchange frames with the
+// client, which reads and writes resp.Body as an io.ReadWriteCloser.
+func UpgradePeer(resp *http.Response) (net.Conn, bool) {
+	body, ok := resp.Body.(*upgradeBody)
+	if !ok {
+		return nil, false
+	}
+	return body.peer, true
+}
+
+// PipeConn returns the server end of the connection, like UpgradePeer. The
+// body of an upgrade response can be asserted to interface{ PipeConn() net.Conn }.
+func (b *upgradeBody) PipeConn() net.Conn {
+	return b.peer
+}
+
+// WithProtocolUpgrade turns the response into a 101 Switching Protocols to
+// protocol, for clients upgrading to a custom application protocol. The body
+// is the client end of an in-memory connection; the test talks to the client
+// through the server end, see UpgradePeer.
+func WithProtocolUpgrade(protocol string) func(*http.Response) {
+	return func(r *http.Response) {
+		r.StatusCode = http.StatusSwitchingProtocols
+		r.Status = fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode))
+		if r.Header == nil {
+			r.Header = make(http.Header)
+		}
+		r.Header.Set("Connection", "Upgrade")
+		r.Header.Set("Upgrade", protocol)
+
+		client, server := net.Pipe()
+		setBody(r, &upgradeBody{Conn: client, peer: server})
+		r.ContentLength = -1
+	}
+}
+
+// WithWebSocketUpgrade turns the response into a successful WebSocket
+// handshake for the client Sec-WebSocket-Key key.
+func WithWebSocketUpgrade(key string) func(*http.Response) {
+	upgrade := WithProtocolUpgrade("websocket")
+	return func(r *http.Response) {
+		upgrade(r)
+		r.Header.Set("Sec-WebSocket-Accept", websocketAccept(key))
+	}
+}
+
+func websocketAccept(key string) string {
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
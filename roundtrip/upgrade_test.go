@@ -0,0 +1,109 @@
+package roundtrip
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestWithWebSocketUpgrade(t *testing.T) {
+	trt := &TestingRoundTripper{}
+	// sample handshake from RFC 6455 section 1.3
+	trt.AddMockResponse(NewMockResponse(WithWebSocketUpgrade("dGhlIHNhbXBsZSBub25jZQ==")))
+
+	client := &http.Client{Transport: trt}
+	req, _ := http.NewRequest("GET", "https://example.com/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("upgrade request failed: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected status 101, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("unexpected Sec-WebSocket-Accept '%s'", got)
+	}
+	if resp.Header.Get("Upgrade") != "websocket" || resp.Header.Get("Connection") != "Upgrade" {
+		t.Errorf("missing upgrade headers: %v", resp.Header)
+	}
+
+	conn, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		t.Fatalf("expected body to be an io.ReadWriteCloser")
+	}
+	peer, ok := UpgradePeer(resp)
+	if !ok {
+		t.Fatalf("expected upgrade peer")
+	}
+	defer peer.Close()
+	defer conn.Close()
+
+	// client -> server
+	go func() { _, _ = conn.Write([]byte("ping")) }()
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(peer, buf); err != nil {
+		t.Fatalf("reading from peer: %v", err)
+	}
+	if string(buf) != "ping" {
+		t.Errorf("expected 'ping', got '%s'", string(buf))
+	}
+
+	// server -> client
+	go func() { _, _ = peer.Write([]byte("pong")) }()
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("reading from client end: %v", err)
+	}
+	if string(buf) != "pong" {
+		t.Errorf("expected 'pong', got '%s'", string(buf))
+	}
+}
+
+func TestUpgradePeer_NotUpgraded(t *testing.T) {
+	if _, ok := UpgradePeer(NewMockResponse()); ok {
+		t.Errorf("expected no peer for a regular response")
+	}
+}
+
+func TestWithProtocolUpgrade(t *testing.T) {
+	trt := &TestingRoundTripper{}
+	trt.AddMockResponse(NewMockResponse(WithProtocolUpgrade("chat/1")))
+
+	client := &http.Client{Transport: trt}
+	req, _ := http.NewRequest("GET", "https://example.com/chat", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "chat/1")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("upgrade request failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Upgrade") != "chat/1" {
+		t.Fatalf("expected 101 upgrade to chat/1, got %d %s", resp.StatusCode, resp.Header.Get("Upgrade"))
+	}
+
+	piped, ok := resp.Body.(interface{ PipeConn() net.Conn })
+	if !ok {
+		t.Fatalf("expected body to expose PipeConn, got %T", resp.Body)
+	}
+	server := piped.PipeConn()
+	conn := resp.Body.(io.ReadWriteCloser)
+	defer conn.Close()
+
+	// client sends a frame, the server answers
+	go func() {
+		buf := make([]byte, 5)
+		if _, err := io.ReadFull(server, buf); err == nil && string(buf) == "HELLO" {
+			_, _ = server.Write([]byte("WELCOME"))
+		}
+	}()
+	if _, err := conn.Write([]byte("HELLO")); err != nil {
+		t.Fatalf("writing frame: %v", err)
+	}
+	buf := make([]byte, 7)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "WELCOME" {
+		t.Fatalf("expected 'WELCOME', got '%s' (%v)", buf, err)
+	}
+}